@@ -0,0 +1,229 @@
+package ghttp
+
+import (
+	"net/http"
+	"strings"
+)
+
+type (
+	// Authenticator is the interface to define an authentication scheme for Client.
+	// It must be concurrent-safe.
+	Authenticator interface {
+		// Authenticate is invoked for every request before it's sent.
+		// Return a non-nil error to prevent the request.
+		Authenticate(req *Request) error
+
+		// Challenge is invoked when a request gets a 401 response.
+		// Return true to replay the request, ghttp will invoke Authenticate again before replaying.
+		// Requests whose body cannot be replayed are never challenged.
+		Challenge(req *Request, resp *Response) (bool, error)
+	}
+
+	// AuthenticatorFunc is an adapter to allow the use of ordinary functions as an Authenticator
+	// which never replays requests.
+	AuthenticatorFunc func(req *Request) error
+
+	basicAuthenticator struct {
+		username string
+		password string
+	}
+
+	bearerAuthenticator struct {
+		token string
+	}
+
+	apiKeyAuthenticator struct {
+		name    string
+		value   string
+		inQuery bool
+	}
+
+	authChallenge struct {
+		scheme string
+		params map[string]string
+	}
+)
+
+// Authenticate implements Authenticator interface.
+func (f AuthenticatorFunc) Authenticate(req *Request) error {
+	return f(req)
+}
+
+// Challenge implements Authenticator interface.
+func (f AuthenticatorFunc) Challenge(*Request, *Response) (bool, error) {
+	return false, nil
+}
+
+// NewBasicAuthenticator returns an Authenticator to set basic authentication for every request.
+func NewBasicAuthenticator(username string, password string) Authenticator {
+	return &basicAuthenticator{
+		username: username,
+		password: password,
+	}
+}
+
+// Authenticate implements Authenticator interface.
+func (ba *basicAuthenticator) Authenticate(req *Request) error {
+	req.SetBasicAuth(ba.username, ba.password)
+	return nil
+}
+
+// Challenge implements Authenticator interface.
+func (ba *basicAuthenticator) Challenge(*Request, *Response) (bool, error) {
+	return false, nil
+}
+
+// NewBearerAuthenticator returns an Authenticator to set a static bearer token for every request.
+func NewBearerAuthenticator(token string) Authenticator {
+	return &bearerAuthenticator{
+		token: token,
+	}
+}
+
+// Authenticate implements Authenticator interface.
+func (ba *bearerAuthenticator) Authenticate(req *Request) error {
+	req.SetBearerToken(ba.token)
+	return nil
+}
+
+// Challenge implements Authenticator interface.
+func (ba *bearerAuthenticator) Challenge(*Request, *Response) (bool, error) {
+	return false, nil
+}
+
+// NewAPIKeyAuthenticator returns an Authenticator to set an API key for every request.
+// The key is sent as the named header, or the named query parameter if inQuery is true.
+func NewAPIKeyAuthenticator(name string, value string, inQuery bool) Authenticator {
+	return &apiKeyAuthenticator{
+		name:    name,
+		value:   value,
+		inQuery: inQuery,
+	}
+}
+
+// Authenticate implements Authenticator interface.
+func (aa *apiKeyAuthenticator) Authenticate(req *Request) error {
+	if aa.inQuery {
+		return req.setQuery(Params{aa.name: aa.value})
+	}
+
+	req.Header.Set(aa.name, aa.value)
+	return nil
+}
+
+// Challenge implements Authenticator interface.
+func (aa *apiKeyAuthenticator) Challenge(*Request, *Response) (bool, error) {
+	return false, nil
+}
+
+// UseAuthenticator specifies an Authenticator for c to authenticate every request.
+func (c *Client) UseAuthenticator(authenticator Authenticator) *Client {
+	c.authenticator = authenticator
+	return c
+}
+
+func (c *Client) doWithAuth(req *Request, resp *Response) {
+	if err := c.authenticator.Authenticate(req); err != nil {
+		resp.err = wrapRequestError(req, 0, err)
+		return
+	}
+
+	attempts := c.doWithRetry(req, resp)
+	if resp.err != nil || resp.StatusCode != http.StatusUnauthorized ||
+		(req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return
+	}
+
+	replay, err := c.authenticator.Challenge(req, resp)
+	if err == nil && replay && req.GetBody != nil {
+		req.Body, err = req.GetBody()
+	}
+	if err == nil && replay {
+		err = c.authenticator.Authenticate(req)
+	}
+	if err != nil || !replay {
+		if err != nil {
+			resp.Body.Close()
+			resp.err = wrapRequestError(req, attempts, err)
+		}
+		return
+	}
+
+	resp.Body.Close()
+	*resp = Response{}
+	c.doWithRetry(req, resp)
+}
+
+// parseAuthChallenges parses the challenges of a WWW-Authenticate header value, see RFC 7235 section 4.1.
+// Parameter names are converted to lower case.
+func parseAuthChallenges(s string) []*authChallenge {
+	var (
+		challenges []*authChallenge
+		current    *authChallenge
+	)
+
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\t' || s[i] == ',' {
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(s) && s[j] != ' ' && s[j] != '\t' && s[j] != ',' && s[j] != '=' {
+			j++
+		}
+		token := s[i:j]
+
+		k := j
+		for k < len(s) && (s[k] == ' ' || s[k] == '\t') {
+			k++
+		}
+		if k >= len(s) || s[k] != '=' || current == nil {
+			current = &authChallenge{
+				scheme: token,
+				params: make(map[string]string),
+			}
+			challenges = append(challenges, current)
+			i = j
+			continue
+		}
+
+		// Skip '=' and the optional whitespaces.
+		for k++; k < len(s) && (s[k] == ' ' || s[k] == '\t'); k++ {
+		}
+
+		var value string
+		value, i = parseAuthParamValue(s, k)
+		current.params[strings.ToLower(token)] = value
+	}
+
+	return challenges
+}
+
+// parseAuthParamValue parses a token or quoted-string starting at s[i],
+// returns the value and the position after it.
+func parseAuthParamValue(s string, i int) (string, int) {
+	if i >= len(s) || s[i] != '"' {
+		j := i
+		for j < len(s) && s[j] != ',' && s[j] != ' ' && s[j] != '\t' {
+			j++
+		}
+		return s[i:j], j
+	}
+
+	var sb strings.Builder
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+		case '"':
+			return sb.String(), i + 1
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), i
+}
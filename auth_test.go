@@ -0,0 +1,103 @@
+package ghttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAuthenticator struct {
+	challenges uint64
+}
+
+func (ta *testAuthenticator) Authenticate(req *Request) error {
+	if atomic.LoadUint64(&ta.challenges) > 0 {
+		req.SetBearerToken("refreshed")
+	}
+	return nil
+}
+
+func (ta *testAuthenticator) Challenge(req *Request, resp *Response) (bool, error) {
+	atomic.AddUint64(&ta.challenges, 1)
+	return resp.Header.Get("WWW-Authenticate") == "Bearer", nil
+}
+
+func TestClient_UseAuthenticator(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer refreshed" {
+			w.Header().Set("WWW-Authenticate", r.URL.Query().Get("scheme"))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(r.Method))
+	}))
+	defer ts.Close()
+
+	ta := new(testAuthenticator)
+	client := New().UseAuthenticator(ta)
+	data, err := client.
+		Post(ts.URL+"?scheme=Bearer",
+			WithText("hello"),
+		).
+		EnsureStatusOk().
+		Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, MethodPost, data)
+		assert.Equal(t, uint64(1), ta.challenges)
+	}
+
+	ta = new(testAuthenticator)
+	client = New().UseAuthenticator(ta)
+	resp := client.Get(ts.URL + "?scheme=Basic")
+	require.NoError(t, resp.Err())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// The body cannot be replayed, so it's never challenged.
+	ta = new(testAuthenticator)
+	client = New().UseAuthenticator(ta)
+	resp = client.Post(ts.URL+"?scheme=Bearer",
+		WithBody(&dummyBody{s: "hello"}),
+	)
+	require.NoError(t, resp.Err())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, uint64(0), ta.challenges)
+
+	errForbidden := errors.New("forbidden")
+	client = New().UseAuthenticator(AuthenticatorFunc(func(req *Request) error {
+		return errForbidden
+	}))
+	assert.True(t, errors.Is(client.Get(ts.URL).Err(), errForbidden))
+}
+
+func TestBuiltinAuthenticators(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Api-Key") + "|" + r.URL.Query().Get("api_key")))
+	}))
+	defer ts.Close()
+
+	data, err := New().UseAuthenticator(NewBasicAuthenticator("user", "pass")).Get(ts.URL).Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Basic dXNlcjpwYXNz||", data)
+	}
+
+	data, err = New().UseAuthenticator(NewBearerAuthenticator("token")).Get(ts.URL).Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Bearer token||", data)
+	}
+
+	data, err = New().UseAuthenticator(NewAPIKeyAuthenticator("X-API-Key", "secret", false)).Get(ts.URL).Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "|secret|", data)
+	}
+
+	data, err = New().UseAuthenticator(NewAPIKeyAuthenticator("api_key", "secret", true)).Get(ts.URL).Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "||secret", data)
+	}
+}
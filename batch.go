@@ -0,0 +1,214 @@
+package ghttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	// DefaultBatchParallelism is the max number of concurrent requests of Client.DoAll if not specified.
+	DefaultBatchParallelism = 10
+)
+
+type (
+	// BatchOptions controls the behavior of Client.DoAll.
+	BatchOptions struct {
+		// Context is shared by all requests, it's canceled when the batch is done.
+		// Each request keeps its own context as well, whichever is done first cancels the request.
+		Context context.Context
+
+		// MaxParallelism is the max number of requests in flight, DefaultBatchParallelism if not positive.
+		MaxParallelism int
+
+		// FailFast stops the batch on the first failed request and cancels the ones in flight.
+		// Otherwise all requests are sent and their errors are collected into a *BatchError.
+		FailFast bool
+
+		// OnProgress is called each time a request completes.
+		// The calls are serialized, so it's safe to update a progress bar from it.
+		OnProgress func(progress *BatchProgress)
+	}
+
+	// BatchProgress reports the progress of Client.DoAll.
+	BatchProgress struct {
+		// Index is the position of the completed request.
+		Index    int
+		Response *Response
+
+		// Completed is the number of completed requests so far, including this one.
+		Completed int
+		Total     int
+	}
+
+	// BatchError records the errors of Client.DoAll, indexed in the same order as the requests.
+	// The element is nil if the request succeeded.
+	BatchError struct {
+		Errors []error
+	}
+)
+
+// Error implements error interface.
+func (e *BatchError) Error() string {
+	var first error
+	failed := 0
+	for _, err := range e.Errors {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("ghttp: %d of %d requests failed, the first error: %s", failed, len(e.Errors), first)
+}
+
+// Unwrap unpacks and returns the first error of e.
+func (e *BatchError) Unwrap() error {
+	for _, err := range e.Errors {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DoAll sends reqs concurrently and returns their responses in the same order.
+// A request fails if its response holds an error, use OnAfterResponse hooks to check the status codes.
+// The responses are prefetched, so that the connections can be reused when the batch is done.
+// Each request is sent as a copy, a request with a body can appear more than once only if its body can be replayed.
+// In fail-fast mode, the error of the first failed request is returned, and the requests
+// not sent have their responses hold a canceled error. Otherwise, a *BatchError is returned
+// if any request fails.
+func (c *Client) DoAll(reqs []*Request, opts *BatchOptions) ([]*Response, error) {
+	if opts == nil {
+		opts = new(BatchOptions)
+	}
+
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	parallelism := opts.MaxParallelism
+	if parallelism <= 0 {
+		parallelism = DefaultBatchParallelism
+	}
+	if parallelism > len(reqs) {
+		parallelism = len(reqs)
+	}
+
+	var (
+		resps     = make([]*Response, len(reqs))
+		errs      = make([]error, len(reqs))
+		failed    error
+		completed int
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		indexes   = make(chan int)
+	)
+
+	done := func(i int, resp *Response) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		resps[i] = resp
+		errs[i] = resp.err
+		if resp.err != nil && failed == nil {
+			failed = resp.err
+			if opts.FailFast {
+				cancel()
+			}
+		}
+
+		completed++
+		if opts.OnProgress != nil {
+			opts.OnProgress(&BatchProgress{
+				Index:     i,
+				Response:  resp,
+				Completed: completed,
+				Total:     len(reqs),
+			})
+		}
+	}
+
+	wg.Add(parallelism)
+	for n := 0; n < parallelism; n++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				done(i, c.doInBatch(ctx, reqs[i]))
+			}
+		}()
+	}
+
+dispatch:
+	for i := range reqs {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, req := range reqs {
+		if resps[i] == nil {
+			resp := &Response{err: wrapRequestError(req, 0, ctx.Err())}
+			resps[i] = resp
+			errs[i] = resp.err
+		}
+	}
+
+	if opts.FailFast {
+		if failed == nil {
+			failed = parent.Err()
+		}
+		return resps, failed
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return resps, &BatchError{Errors: errs}
+		}
+	}
+	return resps, nil
+}
+
+// doInBatch sends a copy of req bound to the batch context ctx and prefetches its response.
+// req is left untouched, so that the same request can appear more than once in a batch.
+func (c *Client) doInBatch(ctx context.Context, req *Request) *Response {
+	if err := ctx.Err(); err != nil {
+		return &Response{err: wrapRequestError(req, 0, err)}
+	}
+
+	reqCtx, cancel := context.WithCancel(req.Request.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-reqCtx.Done():
+		}
+	}()
+
+	r := *req
+	r.Request = req.Request.WithContext(reqCtx)
+	r.Header = req.Header.Clone()
+	if req.URL != nil {
+		u := *req.URL
+		r.URL = &u
+	}
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return &Response{err: wrapRequestError(req, 0, err)}
+		}
+		r.Body = body
+	}
+	return c.Do(&r).Prefetch()
+}
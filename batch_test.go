@@ -0,0 +1,136 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_DoAll(t *testing.T) {
+	var inFlight, maxInFlight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+
+		id := r.URL.Query().Get("id")
+		switch id {
+		case "fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "slow":
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+			}
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+		w.Write([]byte(id))
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.OnAfterResponse(func(resp *Response) error {
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(resp.Status)
+		}
+		return nil
+	})
+
+	newRequests := func(ids ...string) []*Request {
+		reqs := make([]*Request, 0, len(ids))
+		for _, id := range ids {
+			req, err := NewRequest(http.MethodGet, ts.URL+"?id="+id)
+			require.NoError(t, err)
+			reqs = append(reqs, req)
+		}
+		return reqs
+	}
+
+	ids := make([]string, 20)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	var progress []int
+	resps, err := client.DoAll(newRequests(ids...), &BatchOptions{
+		MaxParallelism: 3,
+		OnProgress: func(p *BatchProgress) {
+			assert.Equal(t, 20, p.Total)
+			progress = append(progress, p.Completed)
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, resps, 20)
+	for i, resp := range resps {
+		text, err := resp.Text()
+		require.NoError(t, err)
+		assert.Equal(t, ids[i], text)
+	}
+	assert.Len(t, progress, 20)
+	assert.Equal(t, 20, progress[19])
+	assert.True(t, atomic.LoadInt32(&maxInFlight) <= 3)
+
+	resps, err = client.DoAll(newRequests("0", "fail", "2", "fail"), nil)
+	var batchErr *BatchError
+	require.True(t, errors.As(err, &batchErr))
+	assert.Len(t, batchErr.Errors, 4)
+	assert.NoError(t, batchErr.Errors[0])
+	assert.Error(t, batchErr.Errors[1])
+	assert.NoError(t, batchErr.Errors[2])
+	assert.Error(t, batchErr.Errors[3])
+	assert.Contains(t, err.Error(), "2 of 4 requests failed")
+	text, err := resps[2].Text()
+	assert.NoError(t, err)
+	assert.Equal(t, "2", text)
+
+	start := time.Now()
+	reqs := newRequests("slow", "fail", "slow", "3", "4", "5")
+	resps, err = client.DoAll(reqs, &BatchOptions{
+		MaxParallelism: 3,
+		FailFast:       true,
+	})
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.EqualError(t, errors.Unwrap(err), "500 Internal Server Error")
+	require.Len(t, resps, 6)
+	assert.True(t, IsCanceled(resps[0].Err()))
+	assert.True(t, IsCanceled(resps[5].Err()))
+	assert.NoError(t, reqs[0].Context().Err())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resps, err = client.DoAll(newRequests("0", "1"), &BatchOptions{Context: ctx, FailFast: true})
+	assert.True(t, IsCanceled(err))
+	assert.True(t, IsCanceled(resps[0].Err()))
+
+	// The same request can appear more than once, it's left untouched.
+	req, err := NewRequest(http.MethodPost, ts.URL+"?id=dup", WithText("hello"))
+	require.NoError(t, err)
+	dupClient := New().SetDefaultHeaders(Headers{"X-Foo": "bar"})
+	resps, err = dupClient.DoAll([]*Request{req, req, req, req}, &BatchOptions{MaxParallelism: 4})
+	require.NoError(t, err)
+	for _, resp := range resps {
+		text, err := resp.Text()
+		require.NoError(t, err)
+		assert.Equal(t, "dup", text)
+	}
+	assert.Empty(t, req.Header.Get("X-Foo"))
+	assert.NoError(t, req.Context().Err())
+
+	resps, err = client.DoAll(nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, resps)
+}
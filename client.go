@@ -5,14 +5,12 @@ import (
 	"compress/gzip"
 	"crypto/tls"
 	"crypto/x509"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"net/http/cookiejar"
 	neturl "net/url"
 	"strings"
 	"time"
-
-	"golang.org/x/net/publicsuffix"
 )
 
 const (
@@ -31,6 +29,15 @@ type (
 	Client struct {
 		*http.Client
 		limiter            Limiter
+		authenticator      Authenticator
+		netrc              *netrc
+		proxyURL           string
+		baseURL            *neturl.URL
+		defaultHeaders     http.Header
+		defaultQuery       neturl.Values
+		defaultCookies     Cookies
+		coalescer          *coalescer
+		orderedHeaders     bool
 		beforeRequestHooks []BeforeRequestHook
 		afterResponseHooks []AfterResponseHook
 	}
@@ -80,20 +87,30 @@ func (c *Client) DisableRedirect() *Client {
 }
 
 // EnableSession makes c persist cookies across requests given an optional cookie jar.
-// If the jar not specified, ghttp will constructor one provided by net/http/cookiejar package.
+// If the jar not specified, ghttp will use a PersistentJar, which is built on net/http/cookiejar package
+// and can list its cookies, so that they can be exported or saved to disk.
 func (c *Client) EnableSession(jar ...http.CookieJar) *Client {
 	var _jar http.CookieJar
 	if len(jar) > 0 {
 		_jar = jar[0]
 	} else {
-		_jar, _ = cookiejar.New(&cookiejar.Options{
-			PublicSuffixList: publicsuffix.List,
-		})
+		_jar = NewPersistentJar()
 	}
 	c.Jar = _jar
 	return c
 }
 
+// SetBaseURL sets the base URL which the relative request URLs resolve against.
+// The path of a relative URL is appended to the path of the base URL, e.g.
+// "/users" resolves to "https://api.example.com/v1/users" given the base URL "https://api.example.com/v1".
+// The query and fragment are kept as they are in the relative URL. An invalid URL is ignored.
+func (c *Client) SetBaseURL(url string) *Client {
+	if u, err := neturl.Parse(url); err == nil && u.IsAbs() {
+		c.baseURL = u
+	}
+	return c
+}
+
 // SetTimeout sets timeout of the HTTP client, default is 120s.
 func (c *Client) SetTimeout(timeout time.Duration) *Client {
 	c.Timeout = timeout
@@ -105,6 +122,7 @@ func (c *Client) SetProxy(proxy func(*http.Request) (*neturl.URL, error)) *Clien
 	if t, ok := c.Transport.(*http.Transport); ok && t != nil {
 		t.Proxy = proxy
 	}
+	c.proxyURL = ""
 	return c
 }
 
@@ -112,6 +130,7 @@ func (c *Client) SetProxy(proxy func(*http.Request) (*neturl.URL, error)) *Clien
 func (c *Client) SetProxyFromURL(url string) *Client {
 	if fixedURL, err := neturl.Parse(url); err == nil {
 		c.SetProxy(http.ProxyURL(fixedURL))
+		c.proxyURL = url
 	}
 	return c
 }
@@ -184,6 +203,48 @@ func (c *Client) SetCookies(url string, cookies ...*http.Cookie) *Client {
 	return c
 }
 
+// ImportCookies reads cookies in the Netscape cookies.txt format from r and sets them to cookie jar.
+func (c *Client) ImportCookies(r io.Reader) error {
+	if c.Jar == nil {
+		return ErrNilCookieJar
+	}
+
+	cookies, err := ReadNetscapeCookies(r)
+	if err != nil {
+		return err
+	}
+
+	for _, cookie := range cookies {
+		u := &neturl.URL{
+			Scheme: "http",
+			Host:   strings.TrimPrefix(cookie.Domain, "."),
+			Path:   cookie.Path,
+		}
+		if cookie.Secure {
+			u.Scheme = "https"
+		}
+		if !strings.HasPrefix(cookie.Domain, ".") {
+			cookie.Domain = ""
+		}
+		c.SetCookies(u.String(), cookie)
+	}
+	return nil
+}
+
+// ExportCookies writes all cookies in cookie jar to w in the Netscape cookies.txt format.
+// The cookie jar must be able to list its cookies, like PersistentJar, the default one of EnableSession.
+func (c *Client) ExportCookies(w io.Writer) error {
+	if c.Jar == nil {
+		return ErrNilCookieJar
+	}
+
+	jar, ok := c.Jar.(interface{ All() []*http.Cookie })
+	if !ok {
+		return ErrCookieJarNotEnumerable
+	}
+	return WriteNetscapeCookies(w, jar.All())
+}
+
 // OnBeforeRequest appends request hooks into the before request chain.
 func (c *Client) OnBeforeRequest(hooks ...BeforeRequestHook) *Client {
 	c.beforeRequestHooks = append(c.beforeRequestHooks, hooks...)
@@ -191,6 +252,7 @@ func (c *Client) OnBeforeRequest(hooks ...BeforeRequestHook) *Client {
 }
 
 // OnAfterResponse appends response hooks into the after response chain.
+// The error returned by a hook is reported by the response as a *RequestError.
 func (c *Client) OnAfterResponse(hooks ...AfterResponseHook) *Client {
 	c.afterResponseHooks = append(c.afterResponseHooks, hooks...)
 	return c
@@ -269,17 +331,59 @@ func (c *Client) FilterCookie(url string, name string) (*http.Cookie, error) {
 // Do sends a request and returns its  response.
 func (c *Client) Do(req *Request) *Response {
 	resp := new(Response)
+	if req.err != nil {
+		resp.err = wrapRequestError(req, 0, req.err)
+		return resp
+	}
 
+	c.resolveURL(req)
+	c.applyDefaults(req)
 	if err := c.onBeforeRequest(req); err != nil {
-		resp.err = err
+		resp.err = wrapRequestError(req, 0, err)
 		return resp
 	}
 
-	c.doWithRetry(req, resp)
-	c.onAfterResponse(resp)
+	c.applyNetrc(req)
+
+	if c.coalescer != nil {
+		resp = c.coalescer.do(req, func() *Response {
+			return c.send(req, resp)
+		})
+	} else {
+		c.send(req, resp)
+	}
+	c.onAfterResponse(req, resp)
 	return resp
 }
 
+func (c *Client) send(req *Request, resp *Response) *Response {
+	if c.authenticator != nil {
+		c.doWithAuth(req, resp)
+	} else {
+		c.doWithRetry(req, resp)
+	}
+	return resp
+}
+
+func (c *Client) resolveURL(req *Request) {
+	if c.baseURL == nil || req.URL.IsAbs() || req.URL.Host != "" {
+		return
+	}
+
+	u := *c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(req.URL.Path, "/")
+	if u.RawPath != "" || req.URL.RawPath != "" {
+		u.RawPath = strings.TrimSuffix(c.baseURL.EscapedPath(), "/") + "/" +
+			strings.TrimPrefix(req.URL.EscapedPath(), "/")
+	}
+	u.RawQuery = req.URL.RawQuery
+	u.Fragment = req.URL.Fragment
+	req.URL = &u
+	if req.Host == "" {
+		req.Host = u.Host
+	}
+}
+
 func (c *Client) onBeforeRequest(req *Request) error {
 	var err error
 	for _, hook := range c.beforeRequestHooks {
@@ -290,8 +394,16 @@ func (c *Client) onBeforeRequest(req *Request) error {
 	return err
 }
 
-func (c *Client) doWithRetry(req *Request, resp *Response) {
+// doWithRetry sends req with retries if it's configured, and returns the number of attempts.
+func (c *Client) doWithRetry(req *Request, resp *Response) (attempts int) {
 	var err error
+	defer func() {
+		resp.attempts = attempts
+		if resp.err != nil {
+			resp.err = wrapRequestError(req, attempts, resp.err)
+		}
+	}()
+
 	if req.retrier == nil {
 		req.retrier = noRetry
 	} else if req.retrier.maxAttempts > 1 && req.Body != nil && req.GetBody == nil {
@@ -299,7 +411,7 @@ func (c *Client) doWithRetry(req *Request, resp *Response) {
 		body, err = drainBody(req.Body)
 		if err != nil {
 			resp.err = err
-			return
+			return attempts
 		}
 		req.SetBody(body)
 	}
@@ -307,15 +419,16 @@ func (c *Client) doWithRetry(req *Request, resp *Response) {
 	ctx := req.Request.Context()
 	if c.limiter != nil && !c.limiter.Allow(req.Request) {
 		if err = c.limiter.Wait(ctx); err != nil {
-			resp.err = err
-			return
+			resp.err = &RequestError{Kind: ErrRateLimited, Err: err}
+			return attempts
 		}
 	}
 
 	for i := 0; i < req.retrier.maxAttempts; i++ {
+		attempts++
 		resp.Response, resp.err = c.do(req.Request)
 		if ctx.Err() != nil || i >= req.retrier.maxAttempts-1 || !req.retrier.on(resp) {
-			return
+			return attempts
 		}
 
 		if req.GetBody != nil {
@@ -326,14 +439,57 @@ func (c *Client) doWithRetry(req *Request, resp *Response) {
 		case <-time.After(req.retrier.backoff.WaitTime(i, resp)):
 		case <-ctx.Done():
 			resp.err = ctx.Err()
-			return
+			return attempts
 		}
 	}
+	return attempts
+}
+
+// wrapRequestError wraps err into a *RequestError with more details about req.
+func wrapRequestError(req *Request, attempts int, err error) error {
+	e, ok := err.(*RequestError)
+	if !ok {
+		e = &RequestError{
+			Kind: classifyError(err),
+			Err:  err,
+		}
+	}
+
+	e.Method = req.Method
+	e.URL = redactURL(req.URL)
+	e.Attempts = attempts
+	return e
+}
+
+// redactURL returns the string form of u with its password, if any, replaced with "xxxxx".
+func redactURL(u *neturl.URL) string {
+	if u == nil {
+		return ""
+	}
+	if _, ok := u.User.Password(); !ok {
+		return u.String()
+	}
+
+	redacted := *u
+	redacted.User = neturl.UserPassword(u.User.Username(), "xxxxx")
+	return redacted.String()
 }
 
 func (c *Client) do(req *http.Request) (*http.Response, error) {
-	resp, err := c.Client.Do(req)
+	client := c.Client
+	if c.orderedHeaders && headerOrder(req) != nil {
+		client = new(http.Client)
+		*client = *c.Client
+		client.Transport = &orderedTransport{base: c.transport()}
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
+		// The HTTP client returns both the previous response and the error
+		// only if the redirect policy fails.
+		if resp != nil {
+			err = &redirectError{err: err}
+		}
 		return resp, err
 	}
 
@@ -350,7 +506,14 @@ func (c *Client) do(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
-func (c *Client) onAfterResponse(resp *Response) {
+func (c *Client) transport() http.RoundTripper {
+	if c.Transport != nil {
+		return c.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (c *Client) onAfterResponse(req *Request, resp *Response) {
 	if resp.err != nil {
 		return
 	}
@@ -358,7 +521,7 @@ func (c *Client) onAfterResponse(resp *Response) {
 	var err error
 	for _, hook := range c.afterResponseHooks {
 		if err = hook(resp); err != nil {
-			resp.err = err
+			resp.err = wrapRequestError(req, resp.attempts, err)
 			return
 		}
 	}
@@ -187,7 +187,7 @@ func TestClient_OnBeforeRequest(t *testing.T) {
 				"uid": "10086",
 			}),
 		)
-	assert.Equal(t, errMethodNotAllowed, resp.Err())
+	assert.True(t, errors.Is(resp.Err(), errMethodNotAllowed))
 }
 
 func TestClient_OnAfterResponse(t *testing.T) {
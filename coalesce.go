@@ -0,0 +1,146 @@
+package ghttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// coalescer deduplicates identical in-flight requests, like golang.org/x/sync/singleflight.
+	coalescer struct {
+		ignoredHeaders map[string]bool
+		mu             sync.Mutex
+		calls          map[string]*coalescedCall
+	}
+
+	coalescedCall struct {
+		done chan struct{}
+		resp *Response
+
+		// aborted reports whether the request is interrupted by the context of its sender.
+		aborted bool
+	}
+)
+
+// EnableCoalescing makes c deduplicate identical in-flight GET and HEAD requests without a body,
+// so that only one of them goes on the wire and the others wait for its response.
+// The requests are identical if their methods, URLs, hosts and all the headers are the same,
+// except for the given ignoredHeaders, e.g. a request ID header which differs for every request.
+// Every caller receives an independent copy of the response, which is prefetched and shares
+// the body with the others. If the shared request is interrupted by the context of the caller
+// who sent it, the waiting callers whose contexts are still alive send the request again.
+func (c *Client) EnableCoalescing(ignoredHeaders ...string) *Client {
+	ignored := make(map[string]bool, len(ignoredHeaders))
+	for _, h := range ignoredHeaders {
+		ignored[http.CanonicalHeaderKey(h)] = true
+	}
+
+	c.coalescer = &coalescer{
+		ignoredHeaders: ignored,
+		calls:          make(map[string]*coalescedCall),
+	}
+	return c
+}
+
+// DisableCoalescing stops c deduplicating identical in-flight requests.
+func (c *Client) DisableCoalescing() *Client {
+	c.coalescer = nil
+	return c
+}
+
+// key returns the coalescing key of req, or false if req can't be coalesced.
+func (cc *coalescer) key(req *Request) (string, bool) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead ||
+		req.Body != nil && req.Body != http.NoBody {
+		return "", false
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		if !cc.ignoredHeaders[http.CanonicalHeaderKey(k)] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(req.Method)
+	sb.WriteByte(' ')
+	sb.WriteString(req.URL.String())
+	sb.WriteString("\nHost:")
+	sb.WriteString(req.Host)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			sb.WriteByte('\n')
+			sb.WriteString(strconv.Quote(k))
+			sb.WriteByte(':')
+			sb.WriteString(strconv.Quote(v))
+		}
+	}
+	return sb.String(), true
+}
+
+// do calls send for the first of the identical requests in flight and waits for its response for the others.
+func (cc *coalescer) do(req *Request, send func() *Response) *Response {
+	key, ok := cc.key(req)
+	if !ok {
+		return send()
+	}
+
+	ctx := req.Request.Context()
+	for {
+		cc.mu.Lock()
+		call, ok := cc.calls[key]
+		if !ok {
+			break
+		}
+		cc.mu.Unlock()
+
+		select {
+		case <-call.done:
+			if !call.aborted {
+				return call.resp.clone()
+			}
+			// The sender gave up, try again on its own.
+		case <-ctx.Done():
+			return &Response{err: wrapRequestError(req, 0, ctx.Err())}
+		}
+	}
+
+	call := &coalescedCall{done: make(chan struct{})}
+	cc.calls[key] = call
+	cc.mu.Unlock()
+
+	call.resp = send().Prefetch()
+	call.aborted = ctx.Err() != nil
+
+	cc.mu.Lock()
+	delete(cc.calls, key)
+	cc.mu.Unlock()
+	close(call.done)
+
+	return call.resp.clone()
+}
+
+// clone returns a copy of the prefetched resp, the copy shares the content with resp.
+func (resp *Response) clone() *Response {
+	if resp.err != nil {
+		return &Response{err: resp.err}
+	}
+
+	raw := new(http.Response)
+	*raw = *resp.Response
+	raw.Header = resp.Header.Clone()
+	raw.Trailer = resp.Trailer.Clone()
+	raw.Body = ioutil.NopCloser(bytes.NewReader(resp.content))
+	return &Response{
+		Response: raw,
+		content:  resp.content,
+		attempts: resp.attempts,
+	}
+}
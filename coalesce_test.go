@@ -0,0 +1,138 @@
+package ghttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_EnableCoalescing(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	hold := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/wait":
+			<-release
+		case "/hold":
+			select {
+			case <-hold:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("X-Lang", r.Header.Get("Accept-Language"))
+		w.Write([]byte(r.Method + " " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	client := New().EnableCoalescing()
+	client.OnAfterResponse(func(resp *Response) error {
+		resp.Header.Set("X-Hook", "1")
+		return nil
+	})
+
+	const n = 10
+	resps := make([]*Response, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			resps[i] = client.Get(ts.URL + "/wait")
+		}(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	for i, resp := range resps {
+		text, err := resp.Text()
+		require.NoError(t, err)
+		assert.Equal(t, "GET /wait", text)
+		assert.Equal(t, "1", resp.Header.Get("X-Hook"))
+		if i > 0 {
+			assert.False(t, resp.Response == resps[0].Response)
+		}
+	}
+	resps[0].Header.Set("X-Foo", "bar")
+	assert.Empty(t, resps[1].Header.Get("X-Foo"))
+	raw, err := resps[1].Raw()
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), raw.ContentLength)
+
+	// Not in flight anymore, sent again.
+	client.Get(ts.URL + "/wait")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	client = New().EnableCoalescing("x-request-id")
+	tests := []struct {
+		a, b   *Request
+		shared bool
+	}{
+		{mustNewRequest(t, http.MethodGet, ts.URL+"/a", nil), mustNewRequest(t, http.MethodGet, ts.URL+"/a", nil), true},
+		{mustNewRequest(t, http.MethodGet, ts.URL+"/a", nil), mustNewRequest(t, http.MethodHead, ts.URL+"/a", nil), false},
+		{mustNewRequest(t, http.MethodGet, ts.URL+"/a", nil), mustNewRequest(t, http.MethodGet, ts.URL+"/b", nil), false},
+		{mustNewRequest(t, http.MethodGet, ts.URL+"/a", Headers{"Accept-Language": "en"}), mustNewRequest(t, http.MethodGet, ts.URL+"/a", Headers{"Accept-Language": "fr"}), false},
+		{mustNewRequest(t, http.MethodGet, ts.URL+"/a", Headers{"X-Api-Key": "1"}), mustNewRequest(t, http.MethodGet, ts.URL+"/a", Headers{"X-Api-Key": "2"}), false},
+		{mustNewRequest(t, http.MethodGet, ts.URL+"/a", Headers{"X-Api-Key": "1"}), mustNewRequest(t, http.MethodGet, ts.URL+"/a", nil), false},
+		{mustNewRequest(t, http.MethodGet, ts.URL+"/a", Headers{"X-Request-Id": "1"}), mustNewRequest(t, http.MethodGet, ts.URL+"/a", Headers{"X-Request-Id": "2"}), true},
+		{mustNewRequest(t, http.MethodPost, ts.URL+"/a", nil), mustNewRequest(t, http.MethodPost, ts.URL+"/a", nil), false},
+	}
+	for _, test := range tests {
+		ka, oka := client.coalescer.key(test.a)
+		kb, okb := client.coalescer.key(test.b)
+		assert.Equal(t, test.shared, oka && okb && ka == kb)
+	}
+
+	// A waiting caller stops if its context is done.
+	release = make(chan struct{})
+	defer close(release)
+	go client.Get(ts.URL + "/wait")
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	resp := client.Get(ts.URL+"/wait", WithContext(ctx))
+	assert.True(t, IsTimeout(resp.Err()))
+
+	// A waiting caller sends the request again if the sender gives up.
+	atomic.StoreInt32(&hits, 0)
+	leaderCtx, leaderCancel := context.WithCancel(context.Background())
+	leaderDone := make(chan *Response)
+	go func() {
+		leaderDone <- client.Get(ts.URL+"/hold", WithContext(leaderCtx))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	followerDone := make(chan *Response)
+	go func() {
+		followerDone <- client.Get(ts.URL + "/hold")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	leaderCancel()
+	assert.True(t, IsCanceled((<-leaderDone).Err()))
+	time.Sleep(50 * time.Millisecond)
+	close(hold)
+	text, err := (<-followerDone).Text()
+	require.NoError(t, err)
+	assert.Equal(t, "GET /hold", text)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	client.DisableCoalescing()
+	assert.Nil(t, client.coalescer)
+}
+
+func mustNewRequest(t *testing.T, method string, url string, headers Headers) *Request {
+	req, err := NewRequest(method, url)
+	require.NoError(t, err)
+	require.NoError(t, req.SetHeaders(headers).Err())
+	return req
+}
@@ -49,12 +49,27 @@ func bashEscape(s string) string {
 
 // GenCURLCommand is a helper function to convert and returns the CURL command line to an *http.Request.
 func GenCURLCommand(req *http.Request) (string, error) {
+	return genCURLCommand(req, "")
+}
+
+// genCURLCommand is like GenCURLCommand, but emits --netrc (or --netrc-file) instead of the Authorization header
+// if netrcPath is not empty.
+func genCURLCommand(req *http.Request, netrcPath string) (string, error) {
 	var err error
 	cmd := command{}
 	cmd.append(curlCommand)
 	cmd.addFlag("-v")
 	cmd.addFlag("-X", req.Method)
 
+	useNetrc := netrcPath != ""
+	if useNetrc {
+		if netrcPath == DefaultNetrcPath() {
+			cmd.addFlag("--netrc")
+		} else {
+			cmd.addFlag("--netrc-file", netrcPath)
+		}
+	}
+
 	if req.Body != nil {
 		var body *bytes.Buffer
 		body, err = drainBody(req.Body)
@@ -64,9 +79,30 @@ func GenCURLCommand(req *http.Request) (string, error) {
 		}
 	}
 
+	if order := headerOrder(req); order != nil {
+		var headers []string
+		for _, f := range orderedHeaderFields(req, order) {
+			k := http.CanonicalHeaderKey(f.Key)
+			switch {
+			case k == "Host" && f.Value == req.URL.Host,
+				k == "Content-Length",
+				k == "Transfer-Encoding" && len(req.TransferEncoding) == 0,
+				k == "Authorization" && useNetrc:
+				continue
+			}
+			headers = append(headers, fmt.Sprintf("%s: %s", f.Key, f.Value))
+		}
+		if len(headers) > 0 {
+			cmd.addFlag("-H", headers...)
+		}
+
+		cmd.append(bashEscape(req.URL.String()))
+		return cmd.encode(), err
+	}
+
 	keys := make([]string, 0, len(req.Header))
 	for k := range req.Header {
-		if !reqWriteExcludeHeaderDump[k] {
+		if !reqWriteExcludeHeaderDump[k] && !(useNetrc && k == "Authorization") {
 			keys = append(keys, k)
 		}
 	}
@@ -0,0 +1,117 @@
+package ghttp
+
+import (
+	"net/http"
+	neturl "net/url"
+	"sort"
+)
+
+// SetDefaultHeaders sets default headers for all requests sent by c.
+// A header specified by a request overrides all the values of the same header in c, e.g.
+// c.SetDefaultHeaders(Headers{"Accept": []string{"a", "b"}}) and req.SetHeaders(Headers{"Accept": "c"})
+// results in "Accept: c". Headers of unsupported type are ignored.
+func (c *Client) SetDefaultHeaders(headers Headers) *Client {
+	if c.defaultHeaders == nil {
+		c.defaultHeaders = make(http.Header)
+	}
+	for k, vs := range headers.Decode() {
+		c.defaultHeaders[http.CanonicalHeaderKey(k)] = vs
+	}
+	return c
+}
+
+// AddDefaultHeader appends a value to the default header associated with key.
+func (c *Client) AddDefaultHeader(key string, value string) *Client {
+	if c.defaultHeaders == nil {
+		c.defaultHeaders = make(http.Header)
+	}
+	c.defaultHeaders.Add(key, value)
+	return c
+}
+
+// SetDefaultUserAgent sets default User-Agent header value for all requests sent by c.
+func (c *Client) SetDefaultUserAgent(userAgent string) *Client {
+	return c.SetDefaultHeaders(Headers{"User-Agent": userAgent})
+}
+
+// SetDefaultBasicAuth sets default basic authentication for all requests sent by c.
+func (c *Client) SetDefaultBasicAuth(username string, password string) *Client {
+	return c.SetDefaultHeaders(Headers{"Authorization": "Basic " + basicAuth(username, password)})
+}
+
+// SetDefaultBearerToken sets default bearer token for all requests sent by c.
+func (c *Client) SetDefaultBearerToken(token string) *Client {
+	return c.SetDefaultHeaders(Headers{"Authorization": "Bearer " + token})
+}
+
+// SetDefaultQuery sets default query parameters for all requests sent by c.
+// Like headers, a query parameter specified by a request overrides all the values of the same parameter in c.
+// Query parameters of unsupported type are ignored.
+func (c *Client) SetDefaultQuery(params Params) *Client {
+	if c.defaultQuery == nil {
+		c.defaultQuery = make(neturl.Values)
+	}
+	for k, vs := range params.Decode() {
+		c.defaultQuery[k] = vs
+	}
+	return c
+}
+
+// AddDefaultQuery appends a value to the default query parameter associated with key.
+func (c *Client) AddDefaultQuery(key string, value string) *Client {
+	if c.defaultQuery == nil {
+		c.defaultQuery = make(neturl.Values)
+	}
+	c.defaultQuery.Add(key, value)
+	return c
+}
+
+// SetDefaultCookies sets default cookies for all requests sent by c.
+// A cookie specified by a request overrides the cookie of the same name in c.
+// Unlike the cookie jar, default cookies are sent to any host.
+func (c *Client) SetDefaultCookies(cookies Cookies) *Client {
+	if c.defaultCookies == nil {
+		c.defaultCookies = make(Cookies, len(cookies))
+	}
+	for k, v := range cookies {
+		c.defaultCookies[k] = v
+	}
+	return c
+}
+
+// applyDefaults merges the defaults of c into req, the values specified by req take precedence.
+func (c *Client) applyDefaults(req *Request) {
+	for k, vs := range c.defaultHeaders {
+		if len(req.Header[k]) == 0 {
+			req.Header[k] = append([]string(nil), vs...)
+		}
+	}
+
+	if len(c.defaultQuery) > 0 {
+		query := req.URL.Query()
+		var modified bool
+		for k, vs := range c.defaultQuery {
+			if _, ok := query[k]; !ok {
+				query[k] = vs
+				modified = true
+			}
+		}
+		if modified {
+			req.URL.RawQuery = query.Encode()
+		}
+	}
+
+	names := make([]string, 0, len(c.defaultCookies))
+	for name := range c.defaultCookies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := req.Cookie(name); err == http.ErrNoCookie {
+			req.AddCookie(&http.Cookie{
+				Name:  name,
+				Value: c.defaultCookies[name],
+			})
+		}
+	}
+}
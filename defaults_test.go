@@ -0,0 +1,65 @@
+package ghttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SetDefaults(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(H{
+			"query":   r.URL.Query(),
+			"headers": r.Header,
+			"cookies": r.Header.Get("Cookie"),
+		})
+	}))
+	defer ts.Close()
+
+	client := New().
+		SetDefaultUserAgent("ghttp").
+		SetDefaultHeaders(Headers{"X-Api-Version": "2", "X-Invalid": testInvalidVal}).
+		AddDefaultHeader("Accept", "application/json").
+		AddDefaultHeader("Accept", "text/plain").
+		SetDefaultBearerToken("token").
+		SetDefaultQuery(Params{"tenant": "t1", "tags": []string{"a", "b"}, "invalid": testInvalidVal}).
+		AddDefaultQuery("page", "1").
+		SetDefaultCookies(Cookies{"uid": "1", "lang": "en"})
+
+	var result struct {
+		Query   map[string][]string `json:"query"`
+		Headers map[string][]string `json:"headers"`
+		Cookies string              `json:"cookies"`
+	}
+	err := client.Get(ts.URL+"?page=2",
+		WithQuery(Params{"tags": "c"}),
+		WithHeaders(Headers{"Accept": "application/xml"}),
+		WithBasicAuth("user", "pass"),
+		WithCookies(Cookies{"uid": "2"}),
+	).JSON(&result)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]string{
+		"tenant": {"t1"},
+		"tags":   {"c"},
+		"page":   {"2"},
+	}, result.Query)
+	assert.Equal(t, []string{"ghttp"}, result.Headers["User-Agent"])
+	assert.Equal(t, []string{"2"}, result.Headers["X-Api-Version"])
+	assert.NotContains(t, result.Headers, "X-Invalid")
+	assert.Equal(t, []string{"application/xml"}, result.Headers["Accept"])
+	assert.Equal(t, []string{"Basic dXNlcjpwYXNz"}, result.Headers["Authorization"])
+	assert.Equal(t, "uid=2; lang=en", result.Cookies)
+
+	err = client.Get(ts.URL).JSON(&result)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, result.Query["tags"])
+	assert.Equal(t, []string{"application/json", "text/plain"}, result.Headers["Accept"])
+	assert.Equal(t, []string{"Bearer token"}, result.Headers["Authorization"])
+	assert.Equal(t, "lang=en; uid=1", result.Cookies)
+}
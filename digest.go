@@ -0,0 +1,251 @@
+package ghttp
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrDigestChallenge can be used when the server's digest challenge cannot be satisfied.
+	ErrDigestChallenge = errors.New("ghttp: unsupported digest challenge")
+
+	digestAlgorithms = map[string]func() hash.Hash{
+		"MD5":         md5.New,
+		"SHA-256":     sha256.New,
+		"SHA-512-256": sha512.New512_256,
+	}
+
+	// digestAlgorithmPriority lists the supported algorithms from the strongest to the weakest.
+	digestAlgorithmPriority = []string{"SHA-512-256", "SHA-256", "MD5"}
+
+	digestCnonce = func() string {
+		b := make([]byte, 16)
+		io.ReadFull(rand.Reader, b)
+		return hex.EncodeToString(b)
+	}
+)
+
+type (
+	// DigestAuthenticator is an Authenticator for HTTP Digest Access Authentication, see RFC 7616.
+	// It handles the 401 challenge and replays the request, then reuses the nonce for subsequent requests
+	// until the server sends a new challenge.
+	// It supports MD5, SHA-256, SHA-512-256 and their -sess variants, with qop=auth or qop=auth-int.
+	DigestAuthenticator struct {
+		username  string
+		password  string
+		mu        sync.Mutex
+		challenge *digestChallenge
+		nc        uint32
+	}
+
+	digestChallenge struct {
+		realm     string
+		nonce     string
+		opaque    string
+		algorithm string
+		sess      bool
+		qop       string
+		stale     bool
+		newHash   func() hash.Hash
+	}
+)
+
+// NewDigestAuthenticator returns a new DigestAuthenticator given the username and password.
+func NewDigestAuthenticator(username string, password string) *DigestAuthenticator {
+	return &DigestAuthenticator{
+		username: username,
+		password: password,
+	}
+}
+
+// Authenticate implements Authenticator interface.
+// It does nothing until the server sends a challenge.
+func (da *DigestAuthenticator) Authenticate(req *Request) error {
+	da.mu.Lock()
+	if da.challenge == nil {
+		da.mu.Unlock()
+		return nil
+	}
+	da.nc++
+	challenge, nc := da.challenge, da.nc
+	da.mu.Unlock()
+
+	auth, err := challenge.authorize(req, da.username, da.password, nc, digestCnonce())
+	if err != nil {
+		return &Error{
+			Op:  "DigestAuthenticator.Authenticate",
+			Err: err,
+		}
+	}
+
+	req.Header.Set("Authorization", auth)
+	return nil
+}
+
+// Challenge implements Authenticator interface.
+func (da *DigestAuthenticator) Challenge(req *Request, resp *Response) (bool, error) {
+	challenge := selectDigestChallenge(resp.Header[http.CanonicalHeaderKey("WWW-Authenticate")])
+	if challenge == nil {
+		return false, nil
+	}
+
+	da.mu.Lock()
+	defer da.mu.Unlock()
+
+	// The same nonce is rejected without stale flag means the credentials are wrong.
+	if da.challenge != nil && da.challenge.nonce == challenge.nonce && !challenge.stale {
+		return false, nil
+	}
+
+	da.challenge = challenge
+	da.nc = 0
+	return true, nil
+}
+
+func selectDigestChallenge(values []string) *digestChallenge {
+	var selected *digestChallenge
+	rank := func(c *digestChallenge) int {
+		for i, alg := range digestAlgorithmPriority {
+			if c.algorithm == alg {
+				return len(digestAlgorithmPriority) - i
+			}
+		}
+		return 0
+	}
+
+	for _, v := range values {
+		for _, c := range parseAuthChallenges(v) {
+			if !strings.EqualFold(c.scheme, "Digest") {
+				continue
+			}
+
+			dc := newDigestChallenge(c.params)
+			if dc != nil && (selected == nil || rank(dc) > rank(selected)) {
+				selected = dc
+			}
+		}
+	}
+	return selected
+}
+
+func newDigestChallenge(params map[string]string) *digestChallenge {
+	c := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: strings.ToUpper(params["algorithm"]),
+		stale:     strings.EqualFold(params["stale"], "true"),
+	}
+	if c.nonce == "" {
+		return nil
+	}
+
+	if c.algorithm == "" {
+		c.algorithm = "MD5"
+	}
+	if strings.HasSuffix(c.algorithm, "-SESS") {
+		c.algorithm = strings.TrimSuffix(c.algorithm, "-SESS")
+		c.sess = true
+	}
+
+	var ok bool
+	if c.newHash, ok = digestAlgorithms[c.algorithm]; !ok {
+		return nil
+	}
+
+	if qop, ok := params["qop"]; ok {
+		for _, v := range strings.Split(qop, ",") {
+			v = strings.ToLower(strings.TrimSpace(v))
+			if v == "auth" || v == "auth-int" && c.qop == "" {
+				c.qop = v
+			}
+		}
+		if c.qop == "" {
+			return nil
+		}
+	}
+	return c
+}
+
+func (c *digestChallenge) hash(s string) string {
+	h := c.newHash()
+	io.WriteString(h, s)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *digestChallenge) bodyHash(req *Request) (string, error) {
+	h := c.newHash()
+	if req.Body == nil || req.Body == http.NoBody {
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	if req.GetBody == nil {
+		return "", ErrDigestChallenge
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	if _, err = io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *digestChallenge) authorize(req *Request, username string, password string, nc uint32, cnonce string) (string, error) {
+	uri := req.URL.RequestURI()
+	ha1 := c.hash(username + ":" + c.realm + ":" + password)
+	if c.sess {
+		ha1 = c.hash(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+
+	a2 := req.Method + ":" + uri
+	if c.qop == "auth-int" {
+		bodyHash, err := c.bodyHash(req)
+		if err != nil {
+			return "", err
+		}
+		a2 += ":" + bodyHash
+	}
+	ha2 := c.hash(a2)
+
+	var (
+		response string
+		ncValue  = fmt.Sprintf("%08x", nc)
+	)
+	if c.qop == "" {
+		response = c.hash(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = c.hash(ha1 + ":" + c.nonce + ":" + ncValue + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	algorithm := c.algorithm
+	if c.sess {
+		algorithm += "-sess"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `Digest username="%s", realm="%s", uri="%s", algorithm=%s, nonce="%s"`,
+		escapeQuotes(username), escapeQuotes(c.realm), escapeQuotes(uri), algorithm, escapeQuotes(c.nonce))
+	if c.qop != "" {
+		fmt.Fprintf(&sb, `, nc=%s, cnonce="%s", qop=%s`, ncValue, escapeQuotes(cnonce), c.qop)
+	}
+	fmt.Fprintf(&sb, `, response="%s"`, response)
+	if c.opaque != "" {
+		fmt.Fprintf(&sb, `, opaque="%s"`, escapeQuotes(c.opaque))
+	}
+	return sb.String(), nil
+}
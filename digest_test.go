@@ -0,0 +1,123 @@
+package ghttp
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestChallenge_Authorize(t *testing.T) {
+	// Test vectors from RFC 7616 section 3.9.1.
+	const (
+		username = "Mufasa"
+		password = "Circle of Life"
+		cnonce   = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+		header   = `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS", ` +
+			`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=MD5, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`
+	)
+
+	req, err := NewRequest(MethodGet, "http://www.example.org/dir/index.html")
+	require.NoError(t, err)
+
+	challenges := parseAuthChallenges(header)
+	require.Len(t, challenges, 2)
+
+	c := selectDigestChallenge([]string{header})
+	require.NotNil(t, c)
+	assert.Equal(t, "SHA-256", c.algorithm)
+	assert.Equal(t, "auth", c.qop)
+
+	auth, err := c.authorize(req, username, password, 1, cnonce)
+	if assert.NoError(t, err) {
+		assert.Contains(t, auth, `response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"`)
+		assert.Contains(t, auth, `nc=00000001`)
+		assert.Contains(t, auth, `opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+	}
+
+	c = newDigestChallenge(challenges[1].params)
+	require.NotNil(t, c)
+	auth, err = c.authorize(req, username, password, 1, cnonce)
+	if assert.NoError(t, err) {
+		assert.Contains(t, auth, `response="8ca523f5e9506fed4657c9700eebdbec"`)
+	}
+
+	assert.Nil(t, newDigestChallenge(map[string]string{"nonce": "abc", "algorithm": "SHA-1"}))
+	assert.Nil(t, newDigestChallenge(map[string]string{"nonce": "abc", "qop": "unknown"}))
+	assert.Nil(t, newDigestChallenge(map[string]string{"realm": "abc"}))
+}
+
+func TestDigestAuthenticator(t *testing.T) {
+	const (
+		username = "admin"
+		password = "pass"
+		realm    = "ghttp"
+		nonce    = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	)
+
+	var challenges uint64
+	h := func(s string) string {
+		return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		challenge := func() {
+			atomic.AddUint64(&challenges, 1)
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Basic realm="%s", Digest realm="%s", qop="auth-int", algorithm=MD5-sess, nonce="%s"`, realm, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+
+		cs := parseAuthChallenges(r.Header.Get("Authorization"))
+		if len(cs) != 1 || cs[0].scheme != "Digest" {
+			challenge()
+			return
+		}
+
+		params := cs[0].params
+		body, _ := ioutil.ReadAll(r.Body)
+		ha1 := h(h(username+":"+realm+":"+password) + ":" + nonce + ":" + params["cnonce"])
+		ha2 := h(r.Method + ":" + r.URL.RequestURI() + ":" + h(string(body)))
+		want := h(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth-int:" + ha2)
+		if params["response"] != want || params["algorithm"] != "MD5-sess" {
+			challenge()
+			return
+		}
+
+		w.Write([]byte(params["nc"]))
+	}))
+	defer ts.Close()
+
+	client := New().UseAuthenticator(NewDigestAuthenticator(username, password))
+	data, err := client.
+		Post(ts.URL+"/digest?k=v",
+			WithText("hello"),
+		).
+		EnsureStatusOk().
+		Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "00000001", data)
+		assert.Equal(t, uint64(1), atomic.LoadUint64(&challenges))
+	}
+
+	// The nonce is reused for subsequent requests without challenges.
+	data, err = client.
+		Get(ts.URL).
+		EnsureStatusOk().
+		Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "00000002", data)
+		assert.Equal(t, uint64(1), atomic.LoadUint64(&challenges))
+	}
+
+	resp := New().
+		UseAuthenticator(NewDigestAuthenticator(username, "wrong")).
+		Get(ts.URL)
+	require.NoError(t, resp.Err())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
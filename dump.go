@@ -28,6 +28,14 @@ func dumpRequestLine(req *http.Request, w io.Writer) {
 }
 
 func dumpRequestHeaders(req *http.Request, w io.Writer) {
+	if order := headerOrder(req); order != nil {
+		for _, f := range orderedHeaderFields(req, order) {
+			fmt.Fprintf(w, "> %s: %s\r\n", f.Key, f.Value)
+		}
+		io.WriteString(w, ">\r\n")
+		return
+	}
+
 	host := req.Host
 	if req.Host == "" && req.URL != nil {
 		host = req.URL.Host
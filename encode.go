@@ -0,0 +1,397 @@
+package ghttp
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Array formats supported by the struct tags, e.g. `query:"ids,comma"`.
+const (
+	// ArrayFormatRepeat encodes []int{1, 2} as "ids=1&ids=2", it's the default.
+	ArrayFormatRepeat ArrayFormat = iota
+
+	// ArrayFormatComma encodes []int{1, 2} as "ids=1,2".
+	ArrayFormatComma
+
+	// ArrayFormatBrackets encodes []int{1, 2} as "ids[]=1&ids[]=2".
+	ArrayFormatBrackets
+
+	// ArrayFormatIndexed encodes []int{1, 2} as "ids[0]=1&ids[1]=2".
+	ArrayFormatIndexed
+)
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+type (
+	// ArrayFormat specifies how to encode a slice or an array in query parameters, form data or headers.
+	ArrayFormat int
+
+	structEncoder struct {
+		tag    string
+		values map[string][]string
+	}
+
+	fieldOptions struct {
+		omitEmpty   bool
+		arrayFormat ArrayFormat
+		layout      string
+		unix        bool
+	}
+)
+
+// encodeStruct encodes the struct v into key-value pairs given the struct tag, e.g. "query".
+// See Request.SetFormStruct for the supported tag options.
+func encodeStruct(v interface{}, tag string) (map[string][]string, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ghttp: expected a struct, got %T", v)
+	}
+	if !rv.CanAddr() {
+		// Make the fields addressable, so that the pointer-receiver MarshalText can be used.
+		addressable := reflect.New(rv.Type()).Elem()
+		addressable.Set(rv)
+		rv = addressable
+	}
+
+	e := &structEncoder{
+		tag:    tag,
+		values: make(map[string][]string),
+	}
+	if err := e.encodeStruct("", rv); err != nil {
+		return nil, err
+	}
+	return e.values, nil
+}
+
+func (e *structEncoder) encodeStruct(prefix string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, hasTag := field.Tag.Lookup(e.tag)
+		if tag == "-" {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if field.Anonymous && !hasTag {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !ft.Implements(textMarshalerType) &&
+				!reflect.PtrTo(ft).Implements(textMarshalerType) && ft != timeType {
+				for fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						break
+					}
+					fv = fv.Elem()
+				}
+				if fv.Kind() == reflect.Struct {
+					if err := e.encodeStruct(prefix, fv); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, opts := parseFieldTag(tag)
+		if name == "" {
+			name = field.Name
+		}
+		opts.layout = field.Tag.Get("layout")
+		if opts.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		if err := e.encode(joinKey(prefix, name), fv, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *structEncoder) encode(key string, rv reflect.Value, opts *fieldOptions) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.Type().Implements(textMarshalerType) {
+			break
+		}
+		rv = rv.Elem()
+	}
+
+	if s, ok, err := e.encodeScalar(rv, opts); ok || err != nil {
+		if err != nil {
+			return fmt.Errorf("ghttp: can't encode %s: %s", key, err.Error())
+		}
+		e.values[key] = append(e.values[key], s)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		return e.encodeStruct(key, rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("ghttp: can't encode %s: unsupported map type %s", key, rv.Type())
+		}
+		for _, k := range rv.MapKeys() {
+			if err := e.encode(joinKey(key, k.String()), rv.MapIndex(k), &fieldOptions{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		return e.encodeSlice(key, rv, opts)
+	}
+	return fmt.Errorf("ghttp: can't encode %s: unsupported type %s", key, rv.Type())
+}
+
+func (e *structEncoder) encodeSlice(key string, rv reflect.Value, opts *fieldOptions) error {
+	items := make([]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		s, ok, err := e.encodeScalar(indirect(item), opts)
+		if err != nil {
+			return fmt.Errorf("ghttp: can't encode %s: %s", key, err.Error())
+		}
+		if !ok {
+			// Composite items are always indexed, e.g. "users[0][name]=foo".
+			elemOpts := *opts
+			elemOpts.arrayFormat = ArrayFormatIndexed
+			if err = e.encode(key+"["+strconv.Itoa(i)+"]", item, &elemOpts); err != nil {
+				return err
+			}
+			continue
+		}
+		items = append(items, s)
+	}
+
+	switch opts.arrayFormat {
+	case ArrayFormatComma:
+		if len(items) > 0 {
+			e.values[key] = append(e.values[key], strings.Join(items, ","))
+		}
+	case ArrayFormatBrackets:
+		e.values[key+"[]"] = append(e.values[key+"[]"], items...)
+	case ArrayFormatIndexed:
+		for i, item := range items {
+			k := key + "[" + strconv.Itoa(i) + "]"
+			e.values[k] = append(e.values[k], item)
+		}
+	default:
+		e.values[key] = append(e.values[key], items...)
+	}
+	return nil
+}
+
+// encodeScalar encodes rv into a string if it's not a composite value.
+func (e *structEncoder) encodeScalar(rv reflect.Value, opts *fieldOptions) (string, bool, error) {
+	if !rv.IsValid() {
+		return "", false, nil
+	}
+	if rv.Kind() == reflect.Ptr && rv.Type().Elem() == timeType && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Type() == timeType {
+		t := rv.Interface().(time.Time)
+		switch {
+		case opts.unix:
+			return strconv.FormatInt(t.Unix(), 10), true, nil
+		case opts.layout != "":
+			return t.Format(opts.layout), true, nil
+		}
+		return t.Format(time.RFC3339), true, nil
+	}
+
+	if rv.Type().Implements(textMarshalerType) {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "", false, nil
+		}
+		b, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), true, err
+	}
+	if rv.CanAddr() && rv.Addr().Type().Implements(textMarshalerType) {
+		b, err := rv.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), true, err
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), true, nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), true, nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), true, nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes()), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func parseFieldTag(tag string) (string, *fieldOptions) {
+	opts := new(fieldOptions)
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		switch strings.TrimSpace(opt) {
+		case "omitempty":
+			opts.omitEmpty = true
+		case "repeat":
+			opts.arrayFormat = ArrayFormatRepeat
+		case "comma":
+			opts.arrayFormat = ArrayFormatComma
+		case "brackets":
+			opts.arrayFormat = ArrayFormatBrackets
+		case "indexed":
+			opts.arrayFormat = ArrayFormatIndexed
+		case "unix":
+			opts.unix = true
+		}
+	}
+	return parts[0], opts
+}
+
+func joinKey(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "[" + name + "]"
+}
+
+func indirect(rv reflect.Value) reflect.Value {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() || rv.Type().Implements(textMarshalerType) {
+			break
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+// SetQueryStruct sets query parameters for the HTTP request from a struct with "query" tags.
+// See SetFormStruct for details of the tags.
+func (req *Request) SetQueryStruct(v interface{}) error {
+	values, err := encodeStruct(v, "query")
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetQueryStruct",
+			Err: err,
+		}
+	}
+
+	query := req.URL.Query()
+	for k, vs := range values {
+		query[k] = vs
+	}
+	req.URL.RawQuery = query.Encode()
+	return nil
+}
+
+// SetFormStruct sets form payload for the HTTP request from a struct with "form" tags.
+//
+// The tag value is the key name followed by comma-separated options, e.g. `form:"name,omitempty"`.
+// A field with tag "-" is ignored and a field without the tag uses its name as key.
+// Supported options are:
+//
+//	omitempty: omit the field if it has a zero value.
+//	repeat, comma, brackets, indexed: the array format of a slice or an array, see ArrayFormat.
+//	unix: encode a time.Time as Unix seconds.
+//
+// A time.Time is formatted with the layout specified by the "layout" tag, default is time.RFC3339.
+// A nested struct or map is encoded with bracket notation, e.g. "user[name]=foo", while an embedded struct
+// without tag is flattened. A nil pointer is omitted and an encoding.TextMarshaler is encoded by MarshalText.
+func (req *Request) SetFormStruct(v interface{}) error {
+	values, err := encodeStruct(v, "form")
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetFormStruct",
+			Err: err,
+		}
+	}
+
+	req.SetContentType("application/x-www-form-urlencoded")
+	req.SetBody(strings.NewReader(neturl.Values(values).Encode()))
+	return nil
+}
+
+// SetHeadersStruct sets headers for the HTTP request from a struct with "header" tags.
+// See SetFormStruct for details of the tags.
+func (req *Request) SetHeadersStruct(v interface{}) error {
+	values, err := encodeStruct(v, "header")
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetHeadersStruct",
+			Err: err,
+		}
+	}
+
+	for k, vs := range values {
+		k = http.CanonicalHeaderKey(k)
+		if k == "Host" && len(vs) > 0 {
+			req.SetHost(vs[0])
+		} else {
+			req.Header[k] = vs
+		}
+	}
+	return nil
+}
+
+// WithQueryStruct is a request option to set query parameters for the HTTP request from a struct.
+func WithQueryStruct(v interface{}) RequestOption {
+	return func(req *Request) error {
+		return req.SetQueryStruct(v)
+	}
+}
+
+// WithFormStruct is a request option to set form payload for the HTTP request from a struct.
+func WithFormStruct(v interface{}) RequestOption {
+	return func(req *Request) error {
+		return req.SetFormStruct(v)
+	}
+}
+
+// WithHeadersStruct is a request option to set headers for the HTTP request from a struct.
+func WithHeadersStruct(v interface{}) RequestOption {
+	return func(req *Request) error {
+		return req.SetHeadersStruct(v)
+	}
+}
@@ -0,0 +1,109 @@
+package ghttp
+
+import (
+	"io/ioutil"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPagination struct {
+	Page    int `query:"page,omitempty" form:"page,omitempty"`
+	PerPage int `query:"per_page,omitempty" form:"per_page,omitempty"`
+}
+
+type testAddress struct {
+	City string `query:"city" form:"city"`
+	Zip  string `query:"zip,omitempty" form:"zip,omitempty"`
+}
+
+type testSearch struct {
+	testPagination
+	Query     string            `query:"q" form:"q"`
+	Tags      []string          `query:"tags" form:"tags,brackets"`
+	IDs       []int             `query:"ids,comma" form:"ids,indexed"`
+	Since     time.Time         `query:"since" form:"since" layout:"2006-01-02"`
+	Until     *time.Time        `query:"until,unix" form:"until,omitempty"`
+	IP        net.IP            `query:"ip,omitempty" form:"ip,omitempty"`
+	Address   *testAddress      `query:"address" form:"address,omitempty"`
+	Addresses []testAddress     `query:"addresses,omitempty" form:"addresses,omitempty"`
+	Labels    map[string]string `query:"labels,omitempty" form:"labels,omitempty"`
+	Nil       *string           `query:"nil" form:"nil"`
+	Ignored   string            `query:"-" form:"-"`
+	Raw       []byte
+	private   string
+}
+
+func TestRequest_SetQueryStruct(t *testing.T) {
+	until := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	search := &testSearch{
+		testPagination: testPagination{Page: 2},
+		Query:          "golang",
+		Tags:           []string{"a", "b"},
+		IDs:            []int{1, 2},
+		Since:          time.Date(2020, 1, 1, 8, 0, 0, 0, time.UTC),
+		Until:          &until,
+		IP:             net.IPv4(127, 0, 0, 1),
+		Address:        &testAddress{City: "Shenzhen"},
+		Addresses:      []testAddress{{City: "a", Zip: "1"}},
+		Labels:         map[string]string{"k": "v"},
+		Ignored:        "ignored",
+		Raw:            []byte("raw"),
+	}
+
+	req, err := NewRequest(MethodGet, "https://api.example.com/search?page=1", WithQueryStruct(search))
+	require.NoError(t, err)
+	assert.Equal(t, "Raw=raw&"+
+		"address%5Bcity%5D=Shenzhen&"+
+		"addresses%5B0%5D%5Bcity%5D=a&addresses%5B0%5D%5Bzip%5D=1&"+
+		"ids=1%2C2&ip=127.0.0.1&labels%5Bk%5D=v&page=2&q=golang&"+
+		"since=2020-01-01&tags=a&tags=b&until=1577923200", req.URL.RawQuery)
+
+	req, err = NewRequest(MethodPost, "https://api.example.com/search",
+		WithFormStruct(testSearch{Tags: []string{"a", "b"}, IDs: []int{1, 2}, Until: &until}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "Raw=&ids%5B0%5D=1&ids%5B1%5D=2&q=&since=0001-01-01&"+
+		"tags%5B%5D=a&tags%5B%5D=b&until=2020-01-02T00%3A00%3A00Z", string(body))
+
+	type headers struct {
+		UserAgent string   `header:"user-agent"`
+		Accept    []string `header:"Accept"`
+		Host      string   `header:"host,omitempty"`
+	}
+	req, err = NewRequest(MethodGet, "https://api.example.com",
+		WithHeadersStruct(headers{UserAgent: "ghttp", Accept: []string{"a", "b"}, Host: "example.com"}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "ghttp", req.Header.Get("User-Agent"))
+	assert.Equal(t, []string{"a", "b"}, req.Header["Accept"])
+	assert.Equal(t, "example.com", req.Host)
+
+	_, err = NewRequest(MethodGet, "https://api.example.com", WithQueryStruct(1))
+	assert.Error(t, err)
+	_, err = NewRequest(MethodGet, "https://api.example.com", WithQueryStruct(struct{ C chan int }{}))
+	assert.Error(t, err)
+	_, err = NewRequest(MethodGet, "https://api.example.com", WithQueryStruct((*testSearch)(nil)))
+	assert.NoError(t, err)
+
+	// The pointer-receiver MarshalText is used even if the struct is passed by value.
+	type numbers struct {
+		N big.Int   `query:"n"`
+		F big.Float `query:"f"`
+	}
+	q := numbers{}
+	q.N.SetInt64(42)
+	q.F.SetFloat64(1.5)
+	for _, v := range []interface{}{q, &q} {
+		req, err = NewRequest(MethodGet, "https://api.example.com", WithQueryStruct(v))
+		require.NoError(t, err)
+		assert.Equal(t, "f=1.5&n=42", req.URL.RawQuery)
+	}
+}
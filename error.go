@@ -1,16 +1,54 @@
 package ghttp
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+)
+
+const (
+	// maxStatusErrorBodySize limits the body snippet kept in a StatusError.
+	maxStatusErrorBodySize = 1024
 )
 
 var (
 	// ErrNilCookieJar can be used when the cookie jar is nil.
 	ErrNilCookieJar = errors.New("ghttp: nil cookie jar")
 
+	// ErrCookieJarNotEnumerable can be used when the cookie jar can't list its cookies.
+	ErrCookieJarNotEnumerable = errors.New("ghttp: cookie jar not enumerable")
+
 	// ErrNoCookie can be used when a cookie not found in the HTTP response or cookie jar.
 	ErrNoCookie = errors.New("ghttp: named cookie not present")
+
+	// ErrTimeout is the category of errors caused by a timeout, including the context deadline.
+	ErrTimeout = errors.New("ghttp: timeout")
+
+	// ErrCanceled is the category of errors caused by a canceled context.
+	ErrCanceled = errors.New("ghttp: canceled")
+
+	// ErrNetwork is the category of errors occurred at the network level, e.g. connection refused or reset.
+	ErrNetwork = errors.New("ghttp: network failure")
+
+	// ErrDNS is the category of errors occurred while resolving the host.
+	ErrDNS = errors.New("ghttp: DNS failure")
+
+	// ErrTLS is the category of errors occurred while TLS handshaking or verifying certificates.
+	ErrTLS = errors.New("ghttp: TLS failure")
+
+	// ErrRedirect is the category of errors reported by the redirect policy.
+	ErrRedirect = errors.New("ghttp: redirect failure")
+
+	// ErrRateLimited is the category of errors caused by a rate-limiter, or a 429 HTTP response.
+	ErrRateLimited = errors.New("ghttp: rate limited")
+
+	// ErrBadStatus is the category of errors caused by an unexpected HTTP response status code.
+	ErrBadStatus = errors.New("ghttp: bad status")
 )
 
 type (
@@ -19,6 +57,34 @@ type (
 		Op  string
 		Err error
 	}
+
+	// RequestError records a failed HTTP request with its method, URL and the number of attempts.
+	// Use errors.Is to check its category, e.g. errors.Is(err, ErrTimeout).
+	RequestError struct {
+		Method   string
+		URL      string
+		Attempts int
+
+		// Kind is the category of the error, it's one of the ErrTimeout, ErrCanceled, ErrNetwork,
+		// ErrDNS, ErrTLS, ErrRedirect and ErrRateLimited, or nil if unknown.
+		Kind error
+		Err  error
+	}
+
+	// StatusError records an HTTP response whose status code is not expected.
+	// Use errors.As to retrieve it from the error reported by Response.EnsureStatus and its relatives.
+	StatusError struct {
+		StatusCode int
+		Status     string
+		Header     http.Header
+
+		// Body holds the leading bytes of the HTTP response body, at most 1KB.
+		Body []byte
+
+		// Problem holds the decoded HTTP response body, it's nil if the body cannot be decoded.
+		// If the decoded value implements error interface, it's also the wrapped err of the StatusError.
+		Problem interface{}
+	}
 )
 
 // Error implements error interface.
@@ -30,3 +96,175 @@ func (e *Error) Error() string {
 func (e *Error) Unwrap() error {
 	return e.Err
 }
+
+// Error implements error interface.
+// If e carries problem details, its detail will be included.
+func (e *StatusError) Error() string {
+	if p, ok := e.Problem.(*Problem); ok && p.Detail != "" {
+		return fmt.Sprintf("ghttp: bad status (%s): %s", e.Status, p.Detail)
+	}
+
+	return fmt.Sprintf("ghttp: bad status (%s)", e.Status)
+}
+
+// Unwrap unpacks and returns the wrapped err of e.
+func (e *StatusError) Unwrap() error {
+	err, _ := e.Problem.(error)
+	return err
+}
+
+// Is reports whether e matches target, used by errors.Is.
+func (e *StatusError) Is(target error) bool {
+	return target == ErrBadStatus ||
+		target == ErrRateLimited && e.StatusCode == http.StatusTooManyRequests
+}
+
+// Error implements error interface.
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("ghttp [%s %s] (attempts: %d): %s", e.Method, e.URL, e.Attempts, e.Err.Error())
+}
+
+// Unwrap unpacks and returns the wrapped err of e.
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether e matches target, used by errors.Is.
+func (e *RequestError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// classifyError returns the category of err, or nil if unknown.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrCanceled
+	}
+
+	var (
+		dnsErr      *net.DNSError
+		authErr     x509.UnknownAuthorityError
+		hostErr     x509.HostnameError
+		certErr     x509.CertificateInvalidError
+		recordErr   tls.RecordHeaderError
+		netErr      net.Error
+		opErr       *net.OpError
+		redirectErr *redirectError
+	)
+	switch {
+	case errors.As(err, &redirectErr):
+		return ErrRedirect
+	case errors.As(err, &dnsErr):
+		return ErrDNS
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrTimeout
+	case errors.As(err, &authErr), errors.As(err, &hostErr),
+		errors.As(err, &certErr), errors.As(err, &recordErr),
+		isTLSAlert(err):
+		return ErrTLS
+	case errors.As(err, &opErr):
+		return ErrNetwork
+	}
+
+	return nil
+}
+
+// isTLSAlert reports whether err is a TLS alert, sent by either the local or the remote side.
+// crypto/tls does not export its alert type, so it's recognized by the type name, and the alerts
+// received from the remote side are wrapped into a *net.OpError whose Op is "remote error".
+func isTLSAlert(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if opErr, ok := err.(*net.OpError); ok && opErr.Op == "remote error" {
+			return true
+		}
+		if t := reflect.TypeOf(err); t.PkgPath() == "crypto/tls" && t.Name() == "alert" {
+			return true
+		}
+	}
+	return false
+}
+
+type redirectError struct {
+	err error
+}
+
+func (e *redirectError) Error() string {
+	return e.err.Error()
+}
+
+func (e *redirectError) Unwrap() error {
+	return e.err
+}
+
+// IsTimeout reports whether err is caused by a timeout.
+func IsTimeout(err error) bool {
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsCanceled reports whether err is caused by a canceled context.
+func IsCanceled(err error) bool {
+	return errors.Is(err, ErrCanceled) || errors.Is(err, context.Canceled)
+}
+
+// IsNetwork reports whether err occurred at the network level.
+func IsNetwork(err error) bool {
+	return errors.Is(err, ErrNetwork)
+}
+
+// IsDNS reports whether err occurred while resolving the host.
+func IsDNS(err error) bool {
+	return errors.Is(err, ErrDNS)
+}
+
+// IsTLS reports whether err occurred while TLS handshaking or verifying certificates.
+func IsTLS(err error) bool {
+	return errors.Is(err, ErrTLS)
+}
+
+// IsRedirect reports whether err is reported by the redirect policy.
+func IsRedirect(err error) bool {
+	return errors.Is(err, ErrRedirect)
+}
+
+// IsRateLimited reports whether err is caused by a rate-limiter, or a 429 HTTP response.
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsBadStatus reports whether err is caused by an unexpected HTTP response status code.
+func IsBadStatus(err error) bool {
+	return errors.Is(err, ErrBadStatus)
+}
+
+// IsTemporary reports whether err is likely to be temporary, that means the request may succeed if retried.
+// Timeouts, network failures, temporary DNS failures and 408, 429, 502, 503, 504 HTTP responses are considered temporary.
+func IsTemporary(err error) bool {
+	if IsTimeout(err) || IsNetwork(err) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.Temporary()
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,11 +1,20 @@
 package ghttp
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
 )
 
 func TestError(t *testing.T) {
@@ -21,3 +30,137 @@ func TestError(t *testing.T) {
 		assert.True(t, ok)
 	}
 }
+
+func TestRequestError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		case "/redirect":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/throttle":
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer ts.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	closedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedServer.Close()
+
+	client := New().SetTimeout(50 * time.Millisecond)
+	err := client.
+		Get(ts.URL+"/slow",
+			WithRetry(NewRetrier(2, NewConstantBackoff(time.Millisecond, false), func(resp *Response) bool {
+				return resp.Err() != nil
+			})),
+		).
+		Err()
+	var e *RequestError
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Equal(t, MethodGet, e.Method)
+		assert.Equal(t, ts.URL+"/slow", e.URL)
+		assert.Equal(t, 2, e.Attempts)
+		assert.Equal(t, ErrTimeout, e.Kind)
+		assert.Contains(t, e.Error(), "(attempts: 2)")
+	}
+	assert.True(t, IsTimeout(err))
+	assert.True(t, IsTemporary(err))
+	assert.False(t, IsNetwork(err))
+
+	err = client.Get(closedServer.URL).Err()
+	assert.True(t, IsNetwork(err))
+	assert.True(t, IsTemporary(err))
+	assert.False(t, IsTimeout(err))
+
+	// The TLS and DNS cases take longer than the timeout above on a slow machine.
+	err = New().Get(tlsServer.URL).Err()
+	assert.True(t, IsTLS(err))
+	assert.False(t, IsTemporary(err))
+
+	err = New().Get("http://ghttp.invalid").Err()
+	assert.True(t, IsDNS(err))
+
+	err = New().Get("http://user:secret@" + strings.TrimPrefix(closedServer.URL, "http://")).Err()
+	if assert.True(t, errors.As(err, &e)) {
+		assert.NotContains(t, e.URL, "secret")
+		assert.NotContains(t, e.Error(), "secret")
+		assert.Contains(t, e.URL, "user:xxxxx@")
+	}
+
+	errHook := errors.New("hook")
+	err = New().
+		OnBeforeRequest(func(req *Request) error { return errHook }).
+		Get(ts.URL).
+		Err()
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Equal(t, 0, e.Attempts)
+		assert.True(t, errors.Is(err, errHook))
+	}
+
+	err = New().
+		OnAfterResponse(func(resp *Response) error { return errHook }).
+		Get(ts.URL, WithRetry(NewRetrier(2, NewConstantBackoff(time.Millisecond, false), func(resp *Response) bool {
+			return resp.StatusCode == http.StatusOK
+		}))).
+		Err()
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Equal(t, 2, e.Attempts)
+		assert.Equal(t, http.MethodGet, e.Method)
+		assert.True(t, errors.Is(err, errHook))
+	}
+
+	err = New().
+		UseAuthenticator(AuthenticatorFunc(func(req *Request) error { return errHook })).
+		Get(ts.URL).
+		Err()
+	assert.True(t, errors.As(err, &e))
+	assert.True(t, errors.Is(err, errHook))
+
+	errNoRedirect := errors.New("no redirect")
+	err = New().
+		SetRedirect(func(req *http.Request, via []*http.Request) error {
+			return errNoRedirect
+		}).
+		Get(ts.URL + "/redirect").
+		Err()
+	assert.True(t, IsRedirect(err))
+	assert.True(t, errors.Is(err, errNoRedirect))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = New().
+		UseRateLimiter(NewRegexpLimiter(rate.NewLimiter(1, 1))).
+		Get(ts.URL, WithContext(ctx)).
+		Err()
+	assert.True(t, IsRateLimited(err))
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	err = client.Get(ts.URL, WithContext(ctx)).Err()
+	assert.True(t, IsCanceled(err))
+
+	err = client.
+		Get(ts.URL + "/throttle").
+		EnsureStatus2xx().
+		Err()
+	assert.True(t, IsBadStatus(err))
+	assert.True(t, IsRateLimited(err))
+	assert.True(t, IsTemporary(err))
+}
+
+func TestIsTLSAlert(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	// The server rejects the client without a certificate by an alert.
+	err := New().DisableVerify().Get(ts.URL).Err()
+	assert.True(t, isTLSAlert(err))
+	assert.True(t, IsTLS(err))
+
+	assert.False(t, isTLSAlert(errors.New("tls: not an alert")))
+	assert.False(t, isTLSAlert(&net.OpError{Op: "dial", Err: errors.New("tls: not an alert")}))
+}
@@ -1,12 +1,14 @@
 package ghttp
 
 import (
+	"encoding"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
 	neturl "net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 )
@@ -14,7 +16,8 @@ import (
 type (
 	// Values maps a string key to an interface{} type value,
 	// It's typically used for request query parameters, form data or headers,
-	// besides string and []string, its value also supports []byte, bool and number,
+	// besides string and []string, its value also supports []byte, bool, number, time.Time, time.Duration,
+	// fmt.Stringer, encoding.TextMarshaler, slices of them and nested maps,
 	// ghttp will convert to string automatically.
 	Values map[string]interface{}
 
@@ -40,6 +43,9 @@ type (
 		Body     io.ReadCloser
 		Filename string
 		MIME     string
+
+		// reader is the reader which Body derives from, used to know its size and replay it.
+		reader io.Reader
 	}
 )
 
@@ -49,42 +55,140 @@ func (v Values) Set(key string, value interface{}) Values {
 	return v
 }
 
-func translate(v interface{}) (vs []string) {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Print(err)
-		}
-	}()
-
+func translate(v interface{}) ([]string, error) {
 	switch v := v.(type) {
 	case []string:
-		vs = make([]string, len(v))
+		vs := make([]string, len(v))
 		copy(vs, v)
-	default:
-		vs = []string{toString(v)}
+		return vs, nil
+	case []byte:
+		return []string{b2s(v)}, nil
+	case encoding.TextMarshaler, fmt.Stringer:
+		s, err := toString(v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		vs := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			elem := rv.Index(i).Interface()
+			if elem == nil || isNilPointer(elem) {
+				continue
+			}
+
+			s, err := toString(elem)
+			if err != nil {
+				return nil, err
+			}
+			vs = append(vs, s)
+		}
+		return vs, nil
 	}
 
-	return
+	s, err := toString(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
 }
 
-// Decode translates v and returns the equivalent request query parameters, form data or headers.
-// It ignores any unexpected key-value pairs.
-func (v Values) Decode() map[string][]string {
-	vv := make(map[string][]string, len(v))
+// decode flattens v into vv. If strict is false, the values of unsupported type are skipped,
+// otherwise an error is returned. A nil value or nil pointer is always skipped.
+func (v Values) decode(vv map[string][]string, prefix string, strict bool) error {
 	for key, value := range v {
-		if vs := translate(value); len(vs) > 0 {
+		if prefix != "" {
+			key = prefix + "[" + key + "]"
+		}
+		if value == nil || isNilPointer(value) {
+			continue
+		}
+
+		if nested, ok := toValues(value); ok {
+			if err := nested.decode(vv, key, strict); err != nil {
+				return err
+			}
+			continue
+		}
+
+		vs, err := translate(value)
+		if err != nil {
+			if !strict {
+				continue
+			}
+			return fmt.Errorf("ghttp: can't decode %s: %s", key, err.Error())
+		}
+		if len(vs) > 0 {
 			vv[key] = vs
 		}
 	}
+	return nil
+}
+
+// toValues converts v to Values if it's a map with string keys, except for Cookies.
+func toValues(v interface{}) (Values, bool) {
+	switch v := v.(type) {
+	case Values:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	case H:
+		return Values(v), true
+	case Cookies:
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+
+	values := make(Values, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		values[iter.Key().String()] = iter.Value().Interface()
+	}
+	return values, true
+}
+
+// Decode translates v and returns the equivalent request query parameters, form data or headers.
+// A nested map is flattened with bracket notation, e.g. Values{"a": Values{"b": "c"}} is decoded as "a[b]=c".
+// It ignores any unexpected key-value pairs, use DecodeStrict to find out them.
+func (v Values) Decode() map[string][]string {
+	vv := make(map[string][]string, len(v))
+	v.decode(vv, "", false)
 	return vv
 }
 
+// DecodeStrict is like Decode, but returns an error if there is a value of unsupported type.
+func (v Values) DecodeStrict() (map[string][]string, error) {
+	vv := make(map[string][]string, len(v))
+	if err := v.decode(vv, "", true); err != nil {
+		return nil, err
+	}
+	return vv, nil
+}
+
 // URLEncode encodes v into URL form sorted by key if v is considered as request query parameters or form data.
-// It ignores any unexpected key-value pairs.
+// It ignores any unexpected key-value pairs, use URLEncodeStrict to find out them.
 func (v Values) URLEncode(escaped bool) string {
-	vv := v.Decode()
+	return urlEncode(v.Decode(), escaped)
+}
+
+// URLEncodeStrict is like URLEncode, but returns an error if there is a value of unsupported type.
+func (v Values) URLEncodeStrict(escaped bool) (string, error) {
+	vv, err := v.DecodeStrict()
+	if err != nil {
+		return "", err
+	}
+	return urlEncode(vv, escaped), nil
+}
+
+func urlEncode(vv map[string][]string, escaped bool) string {
 	keys := make([]string, 0, len(vv))
-	for k := range v {
+	for k := range vv {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
@@ -136,7 +240,8 @@ func (c Cookies) Decode() []*http.Cookie {
 // FileFromReader constructors a new *File from a reader.
 func FileFromReader(body io.Reader) *File {
 	return &File{
-		Body: toReadCloser(body),
+		Body:   toReadCloser(body),
+		reader: body,
 	}
 }
 
@@ -3,10 +3,13 @@ package ghttp
 import (
 	"io"
 	"io/ioutil"
+	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type (
@@ -40,6 +43,8 @@ func TestValues_Decode(t *testing.T) {
 		"stringVal":   "hello",
 		"stringSlice": []string{"hello", "hi"},
 		"invalid":     testInvalidVal,
+		"nil":         nil,
+		"nilPointer":  (*time.Time)(nil),
 	}
 	vv := v.Decode()
 	assert.Len(t, vv, 2)
@@ -47,11 +52,66 @@ func TestValues_Decode(t *testing.T) {
 	assert.Equal(t, []string{"hello", "hi"}, vv["stringSlice"])
 }
 
+func TestValues_DecodeStrict(t *testing.T) {
+	v := Values{
+		"stringVal":   "hello",
+		"stringSlice": []string{"hello", "hi"},
+		"intSlice":    []int{1, 2},
+		"time":        time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		"duration":    time.Second,
+		"stringer":    Number(1.5),
+		"ip":          net.IPv4(127, 0, 0, 1),
+		"nil":         nil,
+		"nilElem":     []interface{}{"a", nil, (*net.IP)(nil)},
+		"nilPointer":  (*time.Time)(nil),
+		"nilStringer": (*Number)(nil),
+		"nested": map[string]interface{}{
+			"a": "b",
+			"c": Values{"d": []string{"e", "f"}},
+		},
+	}
+	vv, err := v.DecodeStrict()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"stringVal":    {"hello"},
+		"stringSlice":  {"hello", "hi"},
+		"intSlice":     {"1", "2"},
+		"time":         {"2020-01-01T00:00:00Z"},
+		"duration":     {"1s"},
+		"stringer":     {"1.5"},
+		"ip":           {"127.0.0.1"},
+		"nilElem":      {"a"},
+		"nested[a]":    {"b"},
+		"nested[c][d]": {"e", "f"},
+	}, vv)
+	assert.Equal(t, vv, v.Decode())
+
+	v = Values{
+		"invalid": testInvalidVal,
+	}
+	_, err = v.DecodeStrict()
+	assert.Error(t, err)
+	_, err = v.URLEncodeStrict(true)
+	assert.Error(t, err)
+	v = Values{
+		"invalid": []interface{}{"a", testInvalidVal},
+	}
+	_, err = v.DecodeStrict()
+	assert.Error(t, err)
+	v = Values{
+		"nested": map[string]interface{}{"invalid": testInvalidVal},
+	}
+	_, err = v.DecodeStrict()
+	assert.Error(t, err)
+	assert.Empty(t, v.Decode())
+}
+
 func TestValues_URLEncode(t *testing.T) {
 	v := Values{
 		"expr":        "1+2",
 		"stringVal":   "hello",
 		"stringSlice": []string{"hello", "hi"},
+		"invalid":     testInvalidVal,
 	}
 	// fmt.Printf("%q\n", v.URLEncode(true))
 	want := "expr=1%2B2&stringSlice=hello&stringSlice=hi&stringVal=hello"
@@ -59,6 +119,12 @@ func TestValues_URLEncode(t *testing.T) {
 
 	want = "expr=1+2&stringSlice=hello&stringSlice=hi&stringVal=hello"
 	assert.Equal(t, want, v.URLEncode(false))
+
+	delete(v, "invalid")
+	data, err := v.URLEncodeStrict(false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, want, data)
+	}
 }
 
 func TestValues_Marshal(t *testing.T) {
@@ -3,8 +3,11 @@ module github.com/winterssy/ghttp
 go 1.13
 
 require (
+	github.com/fxamacker/cbor/v2 v2.4.0
 	github.com/stretchr/testify v1.4.0
-	golang.org/x/net v0.0.0-20191009170851-d66e71096ffb
-	golang.org/x/text v0.3.0
+	github.com/vmihailenco/msgpack/v4 v4.3.12
+	golang.org/x/net v0.0.0-20200301022130-244492dfa37a
+	golang.org/x/text v0.3.2
 	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
+	google.golang.org/protobuf v1.25.0
 )
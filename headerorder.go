@@ -0,0 +1,537 @@
+package ghttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+const (
+	defaultUserAgent = "Go-http-client/1.1"
+)
+
+type (
+	// HeaderField is a header key-value pair whose key casing is kept as it is.
+	HeaderField struct {
+		Key   string
+		Value string
+	}
+
+	headerOrderContextKey struct{}
+
+	// orderedTransport is a limited HTTP/1.1 transport to send the requests with ordered headers,
+	// since net/http always sorts the headers. Other requests are sent by base.
+	orderedTransport struct {
+		base http.RoundTripper
+	}
+
+	orderedBody struct {
+		io.ReadCloser
+		conn net.Conn
+		once sync.Once
+		done chan struct{}
+	}
+)
+
+// EnableOrderedHeaders makes c send the requests with ordered headers, see SetOrderedHeaders, over a limited
+// HTTP/1.1 transport of ghttp, since net/http always sorts the headers. Its limitations:
+//   - Each request dials a new connection, which is closed after the response body is closed.
+//   - HTTP/2 is not supported.
+//   - Only HTTP and HTTPS proxies are supported.
+//   - If the transport of c is an *http.Transport, only its Proxy, ProxyConnectHeader, DialContext,
+//     TLSClientConfig, TLSHandshakeTimeout and ResponseHeaderTimeout are respected.
+//
+// The requests without ordered headers are sent by the transport of c as usual.
+func (c *Client) EnableOrderedHeaders() *Client {
+	c.orderedHeaders = true
+	return c
+}
+
+// SetOrderedHeaders sets headers for the HTTP request which are sent in the given order with their key casing kept.
+// It replaces any existing values of the same keys. The headers not specified, e.g. the ones set by SetHeaders,
+// are sent after them in key order. Dump, Verbose and Export reflect the order and casing as well.
+//
+// The order and casing only take effect on the wire if the client enables it by EnableOrderedHeaders,
+// otherwise the request is sent by net/http as usual, which canonicalizes and sorts the headers.
+func (req *Request) SetOrderedHeaders(fields ...HeaderField) *Request {
+	// Copy the order, it may be shared with the requests copied from req.
+	order := append([]string(nil), headerOrder(req.Request)...)
+
+	replaced := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		k := http.CanonicalHeaderKey(f.Key)
+		if k == "Host" {
+			req.SetHost(f.Value)
+		} else {
+			if !replaced[k] {
+				req.Header.Del(k)
+				replaced[k] = true
+			}
+			req.Header.Add(k, f.Value)
+		}
+
+		order = appendHeaderOrder(order, f.Key)
+	}
+
+	req.Request = req.Request.WithContext(context.WithValue(req.Context(), headerOrderContextKey{}, order))
+	return req
+}
+
+// WithOrderedHeaders is a request option to set headers for the HTTP request which are sent in the given order
+// with their key casing kept.
+func WithOrderedHeaders(fields ...HeaderField) RequestOption {
+	return func(req *Request) error {
+		req.SetOrderedHeaders(fields...)
+		return nil
+	}
+}
+
+func appendHeaderOrder(order []string, key string) []string {
+	for i, k := range order {
+		if strings.EqualFold(k, key) {
+			order[i] = key
+			return order
+		}
+	}
+	return append(order, key)
+}
+
+func headerOrder(req *http.Request) []string {
+	order, _ := req.Context().Value(headerOrderContextKey{}).([]string)
+	return order
+}
+
+// orderedHeaderFields returns the headers to write for req, the ones specified by order go first.
+// Then Host, User-Agent and the others sorted by key like net/http.
+func orderedHeaderFields(req *http.Request, order []string) []HeaderField {
+	fields := make([]HeaderField, 0, len(req.Header)+4)
+	emitted := make(map[string]bool, len(req.Header)+4)
+	emit := func(key string) {
+		canonicalKey := http.CanonicalHeaderKey(key)
+		if emitted[canonicalKey] {
+			return
+		}
+
+		emitted[canonicalKey] = true
+		for _, v := range outgoingHeaderValues(req, canonicalKey) {
+			fields = append(fields, HeaderField{Key: key, Value: v})
+		}
+	}
+
+	for _, k := range order {
+		emit(k)
+	}
+	emit("Host")
+	emit("User-Agent")
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		if !reqWriteExcludeHeaderDump[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		emit(k)
+	}
+
+	emit("Connection")
+	emit("Content-Length")
+	emit("Transfer-Encoding")
+	return fields
+}
+
+func outgoingHeaderValues(req *http.Request, key string) []string {
+	switch key {
+	case "Host":
+		host := req.Host
+		if host == "" && req.URL != nil {
+			host = req.URL.Host
+		}
+		if host == "" {
+			return nil
+		}
+		return []string{host}
+	case "User-Agent":
+		vs, ok := req.Header[key]
+		if !ok {
+			return []string{defaultUserAgent}
+		}
+		if len(vs) > 0 && vs[0] == "" {
+			return nil
+		}
+		return vs[:1]
+	case "Connection":
+		if req.Close {
+			return []string{"close"}
+		}
+	case "Content-Length":
+		if isChunked(req) {
+			return nil
+		}
+		if req.ContentLength > 0 {
+			return []string{strconv.FormatInt(req.ContentLength, 10)}
+		}
+		switch req.Method {
+		case MethodPost, MethodPut, MethodPatch:
+			return []string{"0"}
+		}
+		return nil
+	case "Transfer-Encoding":
+		if isChunked(req) {
+			return []string{"chunked"}
+		}
+		return nil
+	case "Trailer":
+		return nil
+	}
+	return req.Header[key]
+}
+
+func hasBody(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody
+}
+
+func isChunked(req *http.Request) bool {
+	if !hasBody(req) {
+		return false
+	}
+	return req.ContentLength <= 0 || len(req.TransferEncoding) > 0 && req.TransferEncoding[0] == "chunked"
+}
+
+// writeOrderedRequest writes req in HTTP/1.1 wire format with its headers in order.
+// The request URI is in absolute-form if absolute is true, which is used for HTTP proxies.
+func writeOrderedRequest(w io.Writer, req *http.Request, absolute bool, extra ...HeaderField) error {
+	bw := bufio.NewWriter(w)
+	if err := writeOrderedHead(bw, req, absolute, extra...); err != nil {
+		return err
+	}
+	if hasBody(req) {
+		err := writeOrderedBody(bw, req, req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func writeOrderedHead(bw *bufio.Writer, req *http.Request, absolute bool, extra ...HeaderField) error {
+	fields := append(orderedHeaderFields(req, headerOrder(req)), extra...)
+	if err := validateHeaderFields(fields); err != nil {
+		return err
+	}
+
+	uri := req.URL.RequestURI()
+	if absolute {
+		uri = req.URL.String()
+	}
+
+	fmt.Fprintf(bw, "%s %s HTTP/1.1\r\n", valueOrDefault(req.Method, MethodGet), uri)
+	for _, f := range fields {
+		fmt.Fprintf(bw, "%s: %s\r\n", f.Key, f.Value)
+	}
+	bw.WriteString("\r\n")
+	return nil
+}
+
+// validateHeaderFields reports an error if any of fields can't be written to the wire as it is, like net/http.
+func validateHeaderFields(fields []HeaderField) error {
+	for _, f := range fields {
+		if !httpguts.ValidHeaderFieldName(f.Key) {
+			return fmt.Errorf("invalid header field name %q", f.Key)
+		}
+		if !httpguts.ValidHeaderFieldValue(f.Value) {
+			return fmt.Errorf("invalid header field value %q for key %s", f.Value, f.Key)
+		}
+	}
+	return nil
+}
+
+func writeOrderedBody(bw *bufio.Writer, req *http.Request, body io.Reader) error {
+	if !isChunked(req) {
+		_, err := io.Copy(bw, body)
+		return err
+	}
+
+	cw := httputil.NewChunkedWriter(bw)
+	if _, err := io.Copy(cw, body); err != nil {
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	_, err := bw.WriteString("\r\n")
+	return err
+}
+
+// dumpOrderedRequest is like httputil.DumpRequestOut, but for the requests with ordered headers.
+func dumpOrderedRequest(req *http.Request, withBody bool) ([]byte, error) {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	if err := writeOrderedHead(bw, req, false); err != nil {
+		return nil, err
+	}
+
+	if withBody && hasBody(req) {
+		var body io.ReadCloser
+		if req.GetBody != nil {
+			var err error
+			if body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		} else {
+			b, err := drainBody(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(b.Bytes()))
+			body = ioutil.NopCloser(b)
+		}
+
+		err := writeOrderedBody(bw, req, body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *orderedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if headerOrder(req) == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	resp, err := t.roundTrip(req)
+	if err != nil && hasBody(req) {
+		req.Body.Close()
+	}
+	return resp, err
+}
+
+func (t *orderedTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported protocol scheme %q", req.URL.Scheme)
+	}
+	// Refuse the invalid headers before dialing, they're checked again when written.
+	if err := validateHeaderFields(orderedHeaderFields(req, headerOrder(req))); err != nil {
+		return nil, err
+	}
+
+	base, _ := t.base.(*http.Transport)
+	var proxyURL *neturl.URL
+	if base != nil && base.Proxy != nil {
+		var err error
+		if proxyURL, err = base.Proxy(req); err != nil {
+			return nil, err
+		}
+		if proxyURL != nil && proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+			return nil, fmt.Errorf("unsupported proxy scheme %q for ordered headers", proxyURL.Scheme)
+		}
+	}
+
+	ctx := req.Context()
+	addr := canonicalAddr(req.URL)
+	dialAddr := addr
+	if proxyURL != nil {
+		dialAddr = canonicalAddr(proxyURL)
+	}
+
+	rawConn, err := dialContext(ctx, base, dialAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			rawConn.Close()
+		case <-req.Cancel:
+			rawConn.Close()
+		case <-done:
+		}
+	}()
+	fail := func(err error) (*http.Response, error) {
+		close(done)
+		rawConn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+
+	conn := rawConn
+	if proxyURL != nil && proxyURL.Scheme == "https" {
+		tlsConn, err := tlsHandshake(conn, base, proxyURL.Hostname())
+		if err != nil {
+			return fail(err)
+		}
+		conn = tlsConn
+	}
+
+	var extra []HeaderField
+	if proxyURL != nil {
+		auth := proxyAuthorization(proxyURL)
+		if req.URL.Scheme == "https" {
+			if err = connectTunnel(conn, base, addr, auth); err != nil {
+				return fail(err)
+			}
+		} else if auth != "" {
+			extra = append(extra, HeaderField{Key: "Proxy-Authorization", Value: auth})
+		}
+	}
+
+	if req.URL.Scheme == "https" {
+		tlsConn, err := tlsHandshake(conn, base, req.URL.Hostname())
+		if err != nil {
+			return fail(err)
+		}
+		conn = tlsConn
+	}
+
+	absolute := proxyURL != nil && req.URL.Scheme == "http"
+	if err = writeOrderedRequest(conn, req, absolute, extra...); err != nil {
+		return fail(err)
+	}
+
+	if base != nil && base.ResponseHeaderTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(base.ResponseHeaderTimeout))
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return fail(err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	resp.Body = &orderedBody{
+		ReadCloser: resp.Body,
+		conn:       conn,
+		done:       done,
+	}
+	return resp, nil
+}
+
+// Close implements io.Closer interface.
+func (b *orderedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		close(b.done)
+		b.conn.Close()
+	})
+	return err
+}
+
+func canonicalAddr(u *neturl.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func dialContext(ctx context.Context, base *http.Transport, addr string) (net.Conn, error) {
+	if base != nil && base.DialContext != nil {
+		return base.DialContext(ctx, "tcp", addr)
+	}
+	return new(net.Dialer).DialContext(ctx, "tcp", addr)
+}
+
+func tlsHandshake(conn net.Conn, base *http.Transport, serverName string) (net.Conn, error) {
+	config := new(tls.Config)
+	if base != nil && base.TLSClientConfig != nil {
+		config = base.TLSClientConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = serverName
+	}
+	config.NextProtos = []string{"http/1.1"}
+
+	if base != nil && base.TLSHandshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(base.TLSHandshakeTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func proxyAuthorization(proxyURL *neturl.URL) string {
+	if proxyURL.User == nil {
+		return ""
+	}
+
+	password, _ := proxyURL.User.Password()
+	auth := proxyURL.User.Username() + ":" + password
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+}
+
+func connectTunnel(conn net.Conn, base *http.Transport, addr string, auth string) error {
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &neturl.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if base != nil {
+		for k, vs := range base.ProxyConnectHeader {
+			connectReq.Header[k] = vs
+		}
+	}
+	if auth != "" {
+		connectReq.Header.Set("Proxy-Authorization", auth)
+	}
+	if err := connectReq.Write(conn); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, connectReq)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("proxy CONNECT: " + resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return errors.New("proxy CONNECT: unexpected data after response")
+	}
+	return nil
+}
+
+func valueOrDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
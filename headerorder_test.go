@@ -0,0 +1,253 @@
+package ghttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rawEchoServer replies with the raw request it received.
+func rawEchoServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				raw := new(bytes.Buffer)
+				req, err := http.ReadRequest(bufio.NewReader(io.TeeReader(conn, raw)))
+				if err != nil {
+					return
+				}
+				ioutil.ReadAll(req.Body)
+
+				fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", raw.Len())
+				conn.Write(raw.Bytes())
+			}()
+		}
+	}()
+	return ln
+}
+
+func TestRequest_SetOrderedHeaders(t *testing.T) {
+	ln := rawEchoServer(t)
+	defer ln.Close()
+
+	url := "http://" + ln.Addr().String() + "/post"
+	opts := []RequestOption{
+		WithHeaders(Headers{"X-Extra": "1"}),
+		WithText("hello"),
+		WithOrderedHeaders(
+			HeaderField{Key: "user-agent", Value: "ghttp"},
+			HeaderField{Key: "accept", Value: "*/*"},
+			HeaderField{Key: "HOST", Value: "example.com"},
+			HeaderField{Key: "x-custom-ID", Value: "a"},
+			HeaderField{Key: "x-custom-ID", Value: "b"},
+		),
+	}
+	want := "POST /post HTTP/1.1\r\n" +
+		"user-agent: ghttp\r\n" +
+		"accept: */*\r\n" +
+		"HOST: example.com\r\n" +
+		"x-custom-ID: a\r\n" +
+		"x-custom-ID: b\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"X-Extra: 1\r\n" +
+		"Content-Length: 5\r\n" +
+		"\r\n" +
+		"hello"
+
+	req, err := NewRequest(MethodPost, url, opts...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, req.Header["X-Custom-Id"])
+	assert.Equal(t, "example.com", req.Host)
+
+	dump, err := req.Dump(true)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(dump))
+
+	cmd, err := req.Export()
+	require.NoError(t, err)
+	assert.Equal(t, "curl -v -X 'POST' -d 'hello' -H 'user-agent: ghttp' -H 'accept: */*' -H 'HOST: example.com' "+
+		"-H 'x-custom-ID: a' -H 'x-custom-ID: b' -H 'Content-Type: text/plain; charset=utf-8' -H 'X-Extra: 1' '"+url+"'", cmd)
+
+	client := New().EnableOrderedHeaders()
+	data, err := client.Do(req).Text()
+	require.NoError(t, err)
+	assert.Equal(t, want, data)
+
+	verbose := new(bytes.Buffer)
+	err = client.Post(url, opts...).Verbose(verbose, false)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(verbose.String(), "> POST /post HTTP/1.1\r\n> user-agent: ghttp\r\n> accept: */*\r\n"))
+
+	// The header order survives SetContext and chunked body is supported.
+	req, err = NewRequest(MethodPut, url, WithOrderedHeaders(HeaderField{Key: "x-b", Value: "b"}))
+	require.NoError(t, err)
+	req.SetContext(context.Background()).
+		SetOrderedHeaders(HeaderField{Key: "x-a", Value: "a"}, HeaderField{Key: "X-B", Value: "c"}).
+		SetBody(strings.NewReader("chunked"))
+	req.ContentLength = 0
+	data, err = client.Do(req).Text()
+	require.NoError(t, err)
+	assert.Equal(t, "PUT /post HTTP/1.1\r\n"+
+		"X-B: c\r\n"+
+		"x-a: a\r\n"+
+		"Host: "+ln.Addr().String()+"\r\n"+
+		"User-Agent: Go-http-client/1.1\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"\r\n"+
+		"7\r\nchunked\r\n0\r\n\r\n", data)
+
+	// Sent by net/http as usual if not enabled.
+	data, err = New().Get(url, WithOrderedHeaders(
+		HeaderField{Key: "x-b", Value: "b"},
+		HeaderField{Key: "x-a", Value: "a"},
+	)).Text()
+	require.NoError(t, err)
+	assert.Contains(t, data, "X-A: a\r\nX-B: b\r\n")
+
+	// The invalid headers are refused before dialing rather than injected into the request.
+	for _, f := range []HeaderField{
+		{Key: "X-A\r\nInjected", Value: "v"},
+		{Key: "X-A", Value: "v\r\nInjected: v"},
+	} {
+		err = client.Get("http://127.0.0.1:0", WithOrderedHeaders(f)).Err()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "invalid header field")
+			assert.False(t, IsNetwork(err))
+		}
+
+		req, err = NewRequest(MethodGet, url, WithOrderedHeaders(f))
+		require.NoError(t, err)
+		_, err = req.Dump(false)
+		assert.Error(t, err)
+	}
+
+	// Changing the order of a copied request doesn't affect the original one.
+	req, err = NewRequest(MethodGet, url, WithOrderedHeaders(
+		HeaderField{Key: "x-a", Value: "a"},
+		HeaderField{Key: "x-b", Value: "b"},
+	))
+	require.NoError(t, err)
+	copied := &Request{Request: req.Request.WithContext(req.Context())}
+	copied.Header = req.Header.Clone()
+	copied.SetOrderedHeaders(HeaderField{Key: "X-A", Value: "c"})
+	assert.Equal(t, []string{"x-a", "x-b"}, headerOrder(req.Request))
+	assert.Equal(t, []string{"X-A", "x-b"}, headerOrder(copied.Request))
+}
+
+// connectProxy is an HTTP proxy supports CONNECT tunnels, it rejects the requests without the X-Tunnel header.
+func connectProxy() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			fmt.Fprintf(w, "%t %s", r.URL.IsAbs(), r.Header.Get("Proxy-Authorization"))
+			return
+		}
+		if r.Header.Get("X-Tunnel") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+}
+
+func TestOrderedTransport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+			return
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		fmt.Fprintf(w, "%s %s %s", r.Proto, r.URL.Path, r.Header.Get("X-Order"))
+	}))
+	defer ts.Close()
+
+	order := WithOrderedHeaders(HeaderField{Key: "x-order", Value: "1"})
+
+	// TLS failure must be reported rather than panic.
+	client := New().EnableOrderedHeaders()
+	err := client.Get(ts.URL, order).Err()
+	assert.True(t, IsTLS(err))
+
+	client.DisableVerify()
+	data, err := client.Get(ts.URL+"/redirect", order).Text()
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 /ok 1", data)
+
+	proxy := connectProxy()
+	defer proxy.Close()
+
+	proxyURL := "http://user:pass@" + strings.TrimPrefix(proxy.URL, "http://")
+	client.SetProxyFromURL(proxyURL)
+	data, err = client.Get("http://example.com/", order).Text()
+	require.NoError(t, err)
+	assert.Equal(t, "true Basic dXNlcjpwYXNz", data)
+
+	// HTTPS over a CONNECT tunnel, with the ProxyConnectHeader respected.
+	err = client.Get(ts.URL+"/ok", order).Err()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "403 Forbidden")
+
+	client.Transport.(*http.Transport).ProxyConnectHeader = http.Header{"X-Tunnel": {"1"}}
+	data, err = client.Get(ts.URL+"/ok", order).Text()
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 /ok 1", data)
+
+	client.SetProxyFromURL("http://127.0.0.1:0")
+	err = client.Get(ts.URL, order).Err()
+	assert.True(t, IsNetwork(err))
+
+	client.DisableProxy()
+	client.Transport.(*http.Transport).ResponseHeaderTimeout = 50 * time.Millisecond
+	err = client.Get(ts.URL+"/slow", order).Err()
+	assert.True(t, IsTimeout(err))
+	client.Transport.(*http.Transport).ResponseHeaderTimeout = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = client.Get(ts.URL, order, WithContext(ctx)).Err()
+	assert.True(t, IsCanceled(err))
+
+	_, err = (&orderedTransport{}).RoundTrip(httptest.NewRequest(MethodGet, "ftp://example.com", nil).
+		WithContext(context.WithValue(context.Background(), headerOrderContextKey{}, []string{"x"})))
+	assert.Error(t, err)
+}
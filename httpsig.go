@@ -0,0 +1,636 @@
+package ghttp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSignatureLabel = "sig1"
+)
+
+var (
+	// ErrSignatureMissing can be used when the named signature not found in the HTTP response.
+	ErrSignatureMissing = errors.New("ghttp: signature not present")
+
+	// ErrSignatureInvalid can be used when the signature or content digest doesn't match the message.
+	ErrSignatureInvalid = errors.New("ghttp: invalid signature")
+
+	// ErrSignatureExpired can be used when the signature has expired.
+	ErrSignatureExpired = errors.New("ghttp: signature expired")
+
+	digestAlgorithmsRFC9530 = map[string]func() hash.Hash{
+		"sha-256": sha256.New,
+		"sha-512": sha512.New,
+	}
+
+	defaultSignatureComponents = []string{"@method", "@target-uri"}
+)
+
+type (
+	// SignatureAlgorithm is the interface to define an algorithm for HTTP Message Signatures.
+	SignatureAlgorithm interface {
+		// Name returns the algorithm name registered in the HTTP Signature Algorithms registry.
+		Name() string
+
+		// Sign returns the signature of the signature base.
+		Sign(base []byte) ([]byte, error)
+
+		// Verify verifies the signature of the signature base.
+		Verify(base []byte, signature []byte) error
+	}
+
+	hmacSHA256Algorithm struct {
+		key []byte
+	}
+
+	ed25519Algorithm struct {
+		privateKey ed25519.PrivateKey
+		publicKey  ed25519.PublicKey
+	}
+
+	ecdsaP256SHA256Algorithm struct {
+		privateKey *ecdsa.PrivateKey
+		publicKey  *ecdsa.PublicKey
+	}
+
+	rsaPSSSHA512Algorithm struct {
+		privateKey *rsa.PrivateKey
+		publicKey  *rsa.PublicKey
+	}
+
+	// MessageSigner signs requests with HTTP Message Signatures, see RFC 9421.
+	// It implements Authenticator interface, so it can be used with Client.UseAuthenticator,
+	// or its Authenticate method can be used as a BeforeRequestHook.
+	MessageSigner struct {
+		label      string
+		keyID      string
+		alg        SignatureAlgorithm
+		components []string
+		expires    time.Duration
+		includeAlg bool
+		now        func() time.Time
+	}
+
+	// signatureInput represents a member of the Signature-Input field.
+	signatureInput struct {
+		components []string
+		params     string
+		created    int64
+		expires    int64
+	}
+)
+
+// NewHMACSHA256Algorithm returns the hmac-sha256 SignatureAlgorithm given a shared secret.
+func NewHMACSHA256Algorithm(key []byte) SignatureAlgorithm {
+	return &hmacSHA256Algorithm{key: key}
+}
+
+// Name implements SignatureAlgorithm interface.
+func (*hmacSHA256Algorithm) Name() string {
+	return "hmac-sha256"
+}
+
+// Sign implements SignatureAlgorithm interface.
+func (a *hmacSHA256Algorithm) Sign(base []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write(base)
+	return mac.Sum(nil), nil
+}
+
+// Verify implements SignatureAlgorithm interface.
+func (a *hmacSHA256Algorithm) Verify(base []byte, signature []byte) error {
+	expected, _ := a.Sign(base)
+	if !hmac.Equal(expected, signature) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// NewEd25519Algorithm returns the ed25519 SignatureAlgorithm given an ed25519.PrivateKey or ed25519.PublicKey.
+// A public key can only be used for verification.
+func NewEd25519Algorithm(key crypto.PublicKey) (SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return &ed25519Algorithm{privateKey: k, publicKey: k.Public().(ed25519.PublicKey)}, nil
+	case ed25519.PublicKey:
+		return &ed25519Algorithm{publicKey: k}, nil
+	}
+	return nil, fmt.Errorf("ghttp: unexpected ed25519 key of type %T", key)
+}
+
+// Name implements SignatureAlgorithm interface.
+func (*ed25519Algorithm) Name() string {
+	return "ed25519"
+}
+
+// Sign implements SignatureAlgorithm interface.
+func (a *ed25519Algorithm) Sign(base []byte) ([]byte, error) {
+	if a.privateKey == nil {
+		return nil, errors.New("ghttp: ed25519 private key is required for signing")
+	}
+	return ed25519.Sign(a.privateKey, base), nil
+}
+
+// Verify implements SignatureAlgorithm interface.
+func (a *ed25519Algorithm) Verify(base []byte, signature []byte) error {
+	if !ed25519.Verify(a.publicKey, base, signature) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// NewECDSAP256SHA256Algorithm returns the ecdsa-p256-sha256 SignatureAlgorithm given an *ecdsa.PrivateKey or
+// *ecdsa.PublicKey on the P-256 curve. A public key can only be used for verification.
+func NewECDSAP256SHA256Algorithm(key crypto.PublicKey) (SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return &ecdsaP256SHA256Algorithm{privateKey: k, publicKey: &k.PublicKey}, nil
+	case *ecdsa.PublicKey:
+		return &ecdsaP256SHA256Algorithm{publicKey: k}, nil
+	}
+	return nil, fmt.Errorf("ghttp: unexpected ecdsa key of type %T", key)
+}
+
+// Name implements SignatureAlgorithm interface.
+func (*ecdsaP256SHA256Algorithm) Name() string {
+	return "ecdsa-p256-sha256"
+}
+
+// Sign implements SignatureAlgorithm interface.
+// The signature is the concatenation of r and s, each of them is 32 bytes.
+func (a *ecdsaP256SHA256Algorithm) Sign(base []byte) ([]byte, error) {
+	if a.privateKey == nil {
+		return nil, errors.New("ghttp: ecdsa private key is required for signing")
+	}
+
+	digest := sha256.Sum256(base)
+	r, s, err := ecdsa.Sign(rand.Reader, a.privateKey, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	signature := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(signature[32-len(rb):32], rb)
+	copy(signature[64-len(sb):], sb)
+	return signature, nil
+}
+
+// Verify implements SignatureAlgorithm interface.
+func (a *ecdsaP256SHA256Algorithm) Verify(base []byte, signature []byte) error {
+	if len(signature) != 64 {
+		return ErrSignatureInvalid
+	}
+
+	digest := sha256.Sum256(base)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(a.publicKey, digest[:], r, s) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// NewRSAPSSSHA512Algorithm returns the rsa-pss-sha512 SignatureAlgorithm given an *rsa.PrivateKey or *rsa.PublicKey.
+// A public key can only be used for verification.
+func NewRSAPSSSHA512Algorithm(key crypto.PublicKey) (SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &rsaPSSSHA512Algorithm{privateKey: k, publicKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &rsaPSSSHA512Algorithm{publicKey: k}, nil
+	}
+	return nil, fmt.Errorf("ghttp: unexpected rsa key of type %T", key)
+}
+
+// Name implements SignatureAlgorithm interface.
+func (*rsaPSSSHA512Algorithm) Name() string {
+	return "rsa-pss-sha512"
+}
+
+// Sign implements SignatureAlgorithm interface.
+func (a *rsaPSSSHA512Algorithm) Sign(base []byte) ([]byte, error) {
+	if a.privateKey == nil {
+		return nil, errors.New("ghttp: rsa private key is required for signing")
+	}
+
+	digest := sha512.Sum512(base)
+	return rsa.SignPSS(rand.Reader, a.privateKey, crypto.SHA512, digest[:], &rsa.PSSOptions{
+		SaltLength: 64,
+	})
+}
+
+// Verify implements SignatureAlgorithm interface.
+func (a *rsaPSSSHA512Algorithm) Verify(base []byte, signature []byte) error {
+	digest := sha512.Sum512(base)
+	if err := rsa.VerifyPSS(a.publicKey, crypto.SHA512, digest[:], signature, &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthAuto,
+	}); err != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// NewMessageSigner returns a new MessageSigner given the key id, algorithm and the covered components.
+// Components are derived component names like "@method", "@target-uri", "@authority", "@scheme",
+// "@request-target", "@path" and "@query", or lower-cased header names like "content-digest".
+// If components not specified, default is "@method" and "@target-uri".
+func NewMessageSigner(keyID string, alg SignatureAlgorithm, components ...string) *MessageSigner {
+	if len(components) == 0 {
+		components = defaultSignatureComponents
+	}
+
+	return &MessageSigner{
+		label:      defaultSignatureLabel,
+		keyID:      keyID,
+		alg:        alg,
+		components: components,
+		now:        time.Now,
+	}
+}
+
+// SetLabel sets the label of the signature, default is "sig1".
+func (s *MessageSigner) SetLabel(label string) *MessageSigner {
+	s.label = label
+	return s
+}
+
+// SetExpires makes the signature expire after the given duration.
+func (s *MessageSigner) SetExpires(expires time.Duration) *MessageSigner {
+	s.expires = expires
+	return s
+}
+
+// SetIncludeAlg makes s include the alg parameter in the signature parameters.
+func (s *MessageSigner) SetIncludeAlg(includeAlg bool) *MessageSigner {
+	s.includeAlg = includeAlg
+	return s
+}
+
+// Authenticate implements Authenticator interface.
+func (s *MessageSigner) Authenticate(req *Request) error {
+	return s.Sign(req)
+}
+
+// Challenge implements Authenticator interface.
+func (s *MessageSigner) Challenge(*Request, *Response) (bool, error) {
+	return false, nil
+}
+
+// Sign signs req by setting its Signature-Input and Signature headers.
+// If "content-digest" is covered but not present, a SHA-256 Content-Digest header will be set first.
+func (s *MessageSigner) Sign(req *Request) error {
+	for _, c := range s.components {
+		if c == "content-digest" && req.Header.Get("Content-Digest") == "" {
+			if err := req.SetContentDigest(); err != nil {
+				return err
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(serializeComponents(s.components))
+	created := s.now().Unix()
+	fmt.Fprintf(&sb, ";created=%d", created)
+	if s.expires > 0 {
+		fmt.Fprintf(&sb, ";expires=%d", created+int64(s.expires/time.Second))
+	}
+	fmt.Fprintf(&sb, ";keyid=%s", strconv.Quote(s.keyID))
+	if s.includeAlg {
+		fmt.Fprintf(&sb, ";alg=%s", strconv.Quote(s.alg.Name()))
+	}
+	params := sb.String()
+
+	base, err := signatureBase(s.components, params, func(c string) (string, error) {
+		return requestComponent(req, c)
+	})
+	if err != nil {
+		return &Error{
+			Op:  "MessageSigner.Sign",
+			Err: err,
+		}
+	}
+
+	signature, err := s.alg.Sign(base)
+	if err != nil {
+		return &Error{
+			Op:  "MessageSigner.Sign",
+			Err: err,
+		}
+	}
+
+	req.Header.Add("Signature-Input", s.label+"="+params)
+	req.Header.Add("Signature", s.label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+func serializeComponents(components []string) string {
+	quoted := make([]string, len(components))
+	for i, c := range components {
+		quoted[i] = strconv.Quote(c)
+	}
+	return "(" + strings.Join(quoted, " ") + ")"
+}
+
+func signatureBase(components []string, params string, value func(component string) (string, error)) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, c := range components {
+		v, err := value(c)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s: %s\n", strconv.Quote(c), v)
+	}
+	fmt.Fprintf(&buf, "\"@signature-params\": %s", params)
+	return buf.Bytes(), nil
+}
+
+func requestComponent(req *Request, component string) (string, error) {
+	u := req.URL
+	switch component {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return u.String(), nil
+	case "@authority":
+		host := req.Host
+		if host == "" {
+			host = u.Host
+		}
+		return strings.ToLower(host), nil
+	case "@scheme":
+		return strings.ToLower(u.Scheme), nil
+	case "@request-target":
+		return u.RequestURI(), nil
+	case "@path":
+		if p := u.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + u.RawQuery, nil
+	case "content-length":
+		if req.Header.Get("Content-Length") == "" && req.ContentLength > 0 {
+			return strconv.FormatInt(req.ContentLength, 10), nil
+		}
+	}
+
+	return headerComponent(req.Header, component)
+}
+
+func responseComponent(resp *Response, component string) (string, error) {
+	if component == "@status" {
+		return strconv.Itoa(resp.StatusCode), nil
+	}
+
+	return headerComponent(resp.Header, component)
+}
+
+func headerComponent(header http.Header, component string) (string, error) {
+	if strings.HasPrefix(component, "@") {
+		return "", fmt.Errorf("ghttp: unsupported derived component %q", component)
+	}
+
+	vs, ok := header[http.CanonicalHeaderKey(component)]
+	if !ok {
+		return "", fmt.Errorf("ghttp: component %q not present", component)
+	}
+
+	trimmed := make([]string, len(vs))
+	for i, v := range vs {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+// SetContentDigest sets Content-Digest header for the HTTP request given the digest algorithms, see RFC 9530.
+// Supported algorithms are "sha-256" and "sha-512", if not specified, default is "sha-256".
+func (req *Request) SetContentDigest(algorithms ...string) error {
+	if len(algorithms) == 0 {
+		algorithms = []string{"sha-256"}
+	}
+
+	var b []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			buf, err := drainBody(req.Body)
+			if err != nil {
+				return &Error{
+					Op:  "Request.SetContentDigest",
+					Err: err,
+				}
+			}
+			req.SetBody(buf)
+		}
+
+		body, err := req.GetBody()
+		if err == nil {
+			b, err = ioutil.ReadAll(body)
+			body.Close()
+		}
+		if err != nil {
+			return &Error{
+				Op:  "Request.SetContentDigest",
+				Err: err,
+			}
+		}
+	}
+
+	digest, err := contentDigest(b, algorithms...)
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetContentDigest",
+			Err: err,
+		}
+	}
+
+	req.Header.Set("Content-Digest", digest)
+	return nil
+}
+
+func contentDigest(b []byte, algorithms ...string) (string, error) {
+	members := make([]string, 0, len(algorithms))
+	for _, alg := range algorithms {
+		newHash, ok := digestAlgorithmsRFC9530[alg]
+		if !ok {
+			return "", fmt.Errorf("ghttp: unsupported digest algorithm %q", alg)
+		}
+
+		h := newHash()
+		h.Write(b)
+		members = append(members, alg+"=:"+base64.StdEncoding.EncodeToString(h.Sum(nil))+":")
+	}
+	return strings.Join(members, ", "), nil
+}
+
+// VerifyContentDigest verifies the HTTP response body against its Content-Digest header.
+// All the supported digests in the header must match.
+func (resp *Response) VerifyContentDigest() error {
+	b, err := resp.Prefetch().Content()
+	if err != nil {
+		return err
+	}
+
+	members := splitDictionary(resp.Header.Get("Content-Digest"))
+	if len(members) == 0 {
+		return ErrSignatureMissing
+	}
+
+	verified := false
+	for alg, v := range members {
+		if _, ok := digestAlgorithmsRFC9530[alg]; !ok {
+			continue
+		}
+
+		expected, _ := contentDigest(b, alg)
+		if expected != alg+"="+v {
+			return ErrSignatureInvalid
+		}
+		verified = true
+	}
+	if !verified {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// VerifySignature verifies the HTTP Message Signature of the HTTP response given its label and algorithm.
+// Covered components can be "@status" or header names. If "content-digest" is covered,
+// the HTTP response body will be verified as well.
+func (resp *Response) VerifySignature(label string, alg SignatureAlgorithm) error {
+	if resp.err != nil {
+		return resp.err
+	}
+
+	inputs := splitDictionary(strings.Join(resp.Header[http.CanonicalHeaderKey("Signature-Input")], ", "))
+	signatures := splitDictionary(strings.Join(resp.Header[http.CanonicalHeaderKey("Signature")], ", "))
+	rawInput, ok1 := inputs[label]
+	rawSignature, ok2 := signatures[label]
+	if !ok1 || !ok2 {
+		return ErrSignatureMissing
+	}
+
+	input, err := parseSignatureInput(rawInput)
+	if err != nil {
+		return err
+	}
+	if input.expires > 0 && time.Now().Unix() > input.expires {
+		return ErrSignatureExpired
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.Trim(rawSignature, ":"))
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+
+	base, err := signatureBase(input.components, input.params, func(c string) (string, error) {
+		return responseComponent(resp, c)
+	})
+	if err != nil {
+		return err
+	}
+	if err = alg.Verify(base, signature); err != nil {
+		return err
+	}
+
+	for _, c := range input.components {
+		if c == "content-digest" {
+			return resp.VerifyContentDigest()
+		}
+	}
+	return nil
+}
+
+// splitDictionary splits a structured field dictionary into its members, see RFC 8941 section 3.2.
+// The member values are kept serialized.
+func splitDictionary(s string) map[string]string {
+	members := make(map[string]string)
+	var (
+		depth   int
+		quoted  bool
+		escaped bool
+		start   int
+	)
+	add := func(member string) {
+		member = strings.TrimSpace(member)
+		if i := strings.IndexByte(member, '='); i > 0 {
+			members[strings.TrimSpace(member[:i])] = strings.TrimSpace(member[i+1:])
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			add(s[start:i])
+			start = i + 1
+		}
+	}
+	add(s[start:])
+	return members
+}
+
+func parseSignatureInput(s string) (*signatureInput, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, ErrSignatureInvalid
+	}
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, ErrSignatureInvalid
+	}
+
+	input := &signatureInput{
+		params: s,
+	}
+	for _, c := range strings.Fields(s[1:end]) {
+		name, err := strconv.Unquote(c)
+		if err != nil {
+			return nil, ErrSignatureInvalid
+		}
+		input.components = append(input.components, name)
+	}
+
+	for _, param := range strings.Split(s[end+1:], ";") {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "created":
+			input.created, _ = strconv.ParseInt(kv[1], 10, 64)
+		case "expires":
+			input.expires, _ = strconv.ParseInt(kv[1], 10, 64)
+		}
+	}
+	return input, nil
+}
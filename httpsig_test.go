@@ -0,0 +1,155 @@
+package ghttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSignatureBody    = `{"hello": "world"}`
+	testSignatureCreated = 1618884473
+)
+
+func newTestSignatureRequest(t *testing.T) *Request {
+	// The test request from RFC 9421 section B.2.
+	req, err := NewRequest(MethodPost, "https://example.com/foo?param=Value&Pet=dog",
+		WithHeaders(Headers{
+			"Date":         "Tue, 20 Apr 2021 02:07:55 GMT",
+			"Content-Type": "application/json",
+		}),
+		WithContent([]byte(testSignatureBody)),
+	)
+	require.NoError(t, err)
+	require.NoError(t, req.SetContentDigest("sha-512"))
+	return req
+}
+
+func newTestMessageSigner(keyID string, alg SignatureAlgorithm, components ...string) *MessageSigner {
+	s := NewMessageSigner(keyID, alg, components...)
+	s.now = func() time.Time { return time.Unix(testSignatureCreated, 0) }
+	return s
+}
+
+func TestRequest_SetContentDigest(t *testing.T) {
+	// Test vectors from RFC 9530.
+	req := newTestSignatureRequest(t)
+	assert.Equal(t, "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:",
+		req.Header.Get("Content-Digest"))
+
+	require.NoError(t, req.SetContentDigest())
+	assert.Equal(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", req.Header.Get("Content-Digest"))
+
+	assert.Error(t, req.SetContentDigest("md5"))
+
+	req, err := NewRequest(MethodPost, "https://example.com/foo",
+		WithBody(&dummyBody{s: testSignatureBody}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, req.SetContentDigest())
+	assert.Equal(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", req.Header.Get("Content-Digest"))
+	assert.NotNil(t, req.GetBody)
+}
+
+func TestMessageSigner_Sign(t *testing.T) {
+	// Test vectors from RFC 9421 section B.2.5 and B.2.6.
+	key, _ := base64.StdEncoding.DecodeString("uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ==")
+	req := newTestSignatureRequest(t)
+	signer := newTestMessageSigner("test-shared-secret", NewHMACSHA256Algorithm(key),
+		"date", "@authority", "content-type").
+		SetLabel("sig-b25")
+	require.NoError(t, signer.Sign(req))
+	assert.Equal(t, `sig-b25=("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`,
+		req.Header.Get("Signature-Input"))
+	assert.Equal(t, "sig-b25=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:", req.Header.Get("Signature"))
+
+	der, _ := base64.StdEncoding.DecodeString("MC4CAQAwBQYDK2VwBCIEIJ+DYvh6SEqVTm50DFtMDoQikTmiCqirVv9mWG9qfSnF")
+	privateKey, err := x509.ParsePKCS8PrivateKey(der)
+	require.NoError(t, err)
+	alg, err := NewEd25519Algorithm(privateKey)
+	require.NoError(t, err)
+
+	req = newTestSignatureRequest(t)
+	signer = newTestMessageSigner("test-key-ed25519", alg,
+		"date", "@method", "@path", "@authority", "content-type", "content-length").
+		SetLabel("sig-b26")
+	require.NoError(t, signer.Sign(req))
+	assert.Equal(t, "sig-b26=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:",
+		req.Header.Get("Signature"))
+
+	req = newTestSignatureRequest(t)
+	signer = newTestMessageSigner("test", alg, "x-missing")
+	assert.Error(t, signer.Sign(req))
+
+	_, err = NewEd25519Algorithm("key")
+	assert.Error(t, err)
+}
+
+func TestResponse_VerifySignature(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecdsaAlg, err := NewECDSAP256SHA256Algorithm(ecdsaKey)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaAlg, err := NewRSAPSSSHA512Algorithm(rsaKey)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Echo the request signature input to verify it.
+		w.Header().Set("X-Signature-Input", r.Header.Get("Signature-Input"))
+
+		alg := ecdsaAlg
+		if r.URL.Query().Get("alg") == "rsa" {
+			alg = rsaAlg
+		}
+
+		body := testSignatureBody
+		if r.URL.Query().Get("tamper") != "" {
+			body = `{"hello": "ghttp"}`
+		}
+		digest := "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+		params := fmt.Sprintf(`("@status" "content-type" "content-digest");created=%d;keyid="server"`, time.Now().Unix())
+		base := fmt.Sprintf("\"@status\": 200\n\"content-type\": application/json\n\"content-digest\": %s\n\"@signature-params\": %s", digest, params)
+		signature, _ := alg.Sign([]byte(base))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Digest", digest)
+		w.Header().Set("Signature-Input", "sig1="+params)
+		w.Header().Set("Signature", "sig1=:"+base64.StdEncoding.EncodeToString(signature)+":")
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	ecdsaPub, _ := NewECDSAP256SHA256Algorithm(&ecdsaKey.PublicKey)
+	rsaPub, _ := NewRSAPSSSHA512Algorithm(&rsaKey.PublicKey)
+	client := New().UseAuthenticator(NewMessageSigner("client", ecdsaAlg, "@method", "@target-uri", "content-digest").
+		SetExpires(time.Minute).
+		SetIncludeAlg(true))
+
+	resp := client.Post(ts.URL, WithText("hello"))
+	require.NoError(t, resp.Err())
+	assert.Contains(t, resp.Header.Get("X-Signature-Input"), `;keyid="client";alg="ecdsa-p256-sha256"`)
+	assert.Contains(t, resp.Header.Get("X-Signature-Input"), `;expires=`)
+	assert.NoError(t, resp.VerifySignature("sig1", ecdsaPub))
+	assert.Equal(t, ErrSignatureMissing, resp.VerifySignature("sig2", ecdsaPub))
+	assert.Equal(t, ErrSignatureInvalid, resp.VerifySignature("sig1", rsaPub))
+
+	resp = client.Get(ts.URL + "?alg=rsa")
+	assert.NoError(t, resp.VerifySignature("sig1", rsaPub))
+
+	resp = client.Get(ts.URL + "?tamper=1")
+	assert.Equal(t, ErrSignatureInvalid, resp.VerifySignature("sig1", ecdsaPub))
+}
@@ -0,0 +1,457 @@
+package ghttp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	neturl "net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	netscapeHTTPOnlyPrefix = "#HttpOnly_"
+)
+
+// Cookie file formats supported by PersistentJar.
+const (
+	// CookieFormatJSON is a JSON array of cookies.
+	CookieFormatJSON CookieFormat = iota
+
+	// CookieFormatNetscape is the Netscape/Mozilla cookies.txt format used by curl and wget.
+	CookieFormatNetscape
+)
+
+type (
+	// CookieFormat specifies the file format of cookies.
+	CookieFormat int
+
+	// PersistentJar is a cookie jar which can save to and load from disk.
+	// It's built on net/http/cookiejar with the public suffix list, and keeps track of the cookies it accepted
+	// so that they can be listed, deleted and persisted. It's concurrent safe.
+	PersistentJar struct {
+		mu                 sync.Mutex
+		jar                *cookiejar.Jar
+		entries            map[string]*jarEntry
+		keepSessionCookies bool
+	}
+
+	jarEntry struct {
+		Name     string        `json:"name"`
+		Value    string        `json:"value"`
+		Domain   string        `json:"domain"`
+		Path     string        `json:"path"`
+		Expires  time.Time     `json:"expires,omitempty"`
+		Secure   bool          `json:"secure,omitempty"`
+		HttpOnly bool          `json:"http_only,omitempty"`
+		HostOnly bool          `json:"host_only,omitempty"`
+		SameSite http.SameSite `json:"same_site,omitempty"`
+	}
+)
+
+// NewPersistentJar returns a new, empty PersistentJar.
+func NewPersistentJar() *PersistentJar {
+	return &PersistentJar{
+		jar:     newCookieJar(),
+		entries: make(map[string]*jarEntry),
+	}
+}
+
+func newCookieJar() *cookiejar.Jar {
+	jar, _ := cookiejar.New(&cookiejar.Options{
+		PublicSuffixList: publicsuffix.List,
+	})
+	return jar
+}
+
+// SetKeepSessionCookies makes j save session cookies as well, they're discarded by default like browsers do.
+func (j *PersistentJar) SetKeepSessionCookies(keep bool) *PersistentJar {
+	j.mu.Lock()
+	j.keepSessionCookies = keep
+	j.mu.Unlock()
+	return j
+}
+
+// SetCookies implements http.CookieJar interface.
+func (j *PersistentJar) SetCookies(u *neturl.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.jar.SetCookies(u, cookies)
+	now := time.Now()
+	for _, c := range cookies {
+		e := newJarEntry(u, c, now)
+		key := e.key()
+		if !e.Expires.IsZero() && !e.Expires.After(now) {
+			delete(j.entries, key)
+			continue
+		}
+
+		// Only keep track of the cookies which the underlying jar accepted.
+		if j.accepted(e) {
+			j.entries[key] = e
+		}
+	}
+}
+
+// Cookies implements http.CookieJar interface.
+func (j *PersistentJar) Cookies(u *neturl.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.jar.Cookies(u)
+}
+
+// All returns all the unexpired cookies in j, sorted by domain, path and name.
+// The Domain field of a cookie which matches subdomains starts with a dot.
+func (j *PersistentJar) All() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := j.unexpired(true)
+	cookies := make([]*http.Cookie, len(entries))
+	for i, e := range entries {
+		cookies[i] = e.cookie()
+	}
+	return cookies
+}
+
+// Delete deletes the cookie given its domain, path and name.
+// domain may start with a dot, as the cookies returned by All.
+func (j *PersistentJar) Delete(domain string, path string, name string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	key := (&jarEntry{Domain: strings.TrimPrefix(strings.ToLower(domain), "."), Path: path, Name: name}).key()
+	if _, ok := j.entries[key]; ok {
+		delete(j.entries, key)
+		j.rebuild()
+	}
+}
+
+// Clear deletes all the cookies in j.
+func (j *PersistentJar) Clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = make(map[string]*jarEntry)
+	j.jar = newCookieJar()
+}
+
+// Save writes the unexpired cookies in j to w given the format.
+// Session cookies are discarded unless SetKeepSessionCookies is enabled.
+func (j *PersistentJar) Save(w io.Writer, format CookieFormat) error {
+	j.mu.Lock()
+	entries := j.unexpired(j.keepSessionCookies)
+	j.mu.Unlock()
+
+	switch format {
+	case CookieFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(entries)
+	case CookieFormatNetscape:
+		cookies := make([]*http.Cookie, len(entries))
+		for i, e := range entries {
+			cookies[i] = e.cookie()
+		}
+		return WriteNetscapeCookies(w, cookies)
+	}
+	return fmt.Errorf("ghttp: unknown cookie format %d", format)
+}
+
+// Load reads cookies from r given the format and adds them to j, expired cookies are ignored.
+func (j *PersistentJar) Load(r io.Reader, format CookieFormat) error {
+	var entries []*jarEntry
+	switch format {
+	case CookieFormatJSON:
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return err
+		}
+	case CookieFormatNetscape:
+		cookies, err := ReadNetscapeCookies(r)
+		if err != nil {
+			return err
+		}
+		for _, c := range cookies {
+			entries = append(entries, cookieToJarEntry(c))
+		}
+	default:
+		return fmt.Errorf("ghttp: unknown cookie format %d", format)
+	}
+
+	j.add(entries)
+	return nil
+}
+
+// SaveFile is like Save, but writes to the named file.
+func (j *PersistentJar) SaveFile(filename string, format CookieFormat) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = j.Save(file, format)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// LoadFile is like Load, but reads from the named file.
+func (j *PersistentJar) LoadFile(filename string, format CookieFormat) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return j.Load(file, format)
+}
+
+func (j *PersistentJar) snapshot() []*jarEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.unexpired(true)
+}
+
+func (j *PersistentJar) add(entries []*jarEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range entries {
+		if e.Expires.IsZero() || e.Expires.After(now) {
+			j.restore(e)
+		}
+	}
+}
+
+func (j *PersistentJar) unexpired(withSession bool) []*jarEntry {
+	now := time.Now()
+	entries := make([]*jarEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		if e.Expires.IsZero() && withSession || e.Expires.After(now) {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(i, k int) bool {
+		return entries[i].key() < entries[k].key()
+	})
+	return entries
+}
+
+func (j *PersistentJar) accepted(e *jarEntry) bool {
+	for _, c := range j.jar.Cookies(e.url()) {
+		if c.Name == e.Name && c.Value == e.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *PersistentJar) restore(e *jarEntry) {
+	c := e.cookie()
+	if e.HostOnly {
+		c.Domain = ""
+	}
+
+	j.jar.SetCookies(e.url(), []*http.Cookie{c})
+	if j.accepted(e) {
+		j.entries[e.key()] = e
+	}
+}
+
+func (j *PersistentJar) rebuild() {
+	entries := j.entries
+	j.jar = newCookieJar()
+	j.entries = make(map[string]*jarEntry, len(entries))
+	for _, e := range entries {
+		j.restore(e)
+	}
+}
+
+func newJarEntry(u *neturl.URL, c *http.Cookie, now time.Time) *jarEntry {
+	e := &jarEntry{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   strings.TrimPrefix(strings.ToLower(c.Domain), "."),
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	}
+	if e.Domain == "" {
+		e.Domain = strings.ToLower(u.Hostname())
+		e.HostOnly = true
+	}
+	if e.Path == "" || e.Path[0] != '/' {
+		e.Path = defaultCookiePath(u.Path)
+	}
+
+	switch {
+	case c.MaxAge < 0:
+		e.Expires = time.Unix(1, 0)
+	case c.MaxAge > 0:
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		e.Expires = c.Expires
+	}
+	return e
+}
+
+func cookieToJarEntry(c *http.Cookie) *jarEntry {
+	return &jarEntry{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   strings.TrimPrefix(strings.ToLower(c.Domain), "."),
+		Path:     c.Path,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		HostOnly: !strings.HasPrefix(c.Domain, "."),
+		SameSite: c.SameSite,
+	}
+}
+
+// defaultCookiePath returns the directory part of a URL's path, see RFC 6265 section 5.1.4.
+func defaultCookiePath(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		return "/"
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+func (e *jarEntry) key() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+func (e *jarEntry) url() *neturl.URL {
+	scheme := "http"
+	if e.Secure {
+		scheme = "https"
+	}
+	return &neturl.URL{
+		Scheme: scheme,
+		Host:   e.Domain,
+		Path:   e.Path,
+	}
+}
+
+func (e *jarEntry) cookie() *http.Cookie {
+	domain := e.Domain
+	if !e.HostOnly {
+		domain = "." + domain
+	}
+	return &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Domain:   domain,
+		Path:     e.Path,
+		Expires:  e.Expires,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+		SameSite: e.SameSite,
+	}
+}
+
+// ReadNetscapeCookies parses cookies in the Netscape/Mozilla cookies.txt format, as written by curl -c and wget.
+// Lines prefixed with #HttpOnly_ are read as HttpOnly cookies. The Domain field of a cookie which matches subdomains starts with a dot.
+func ReadNetscapeCookies(r io.Reader) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, netscapeHTTPOnlyPrefix)
+		if httpOnly {
+			line = line[len(netscapeHTTPOnlyPrefix):]
+		} else if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("ghttp: malformed cookies.txt at line %d", lineNum)
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ghttp: malformed cookies.txt at line %d: %s", lineNum, err.Error())
+		}
+
+		domain := strings.ToLower(fields[0])
+		includeSubdomains := strings.EqualFold(fields[1], "TRUE")
+		if includeSubdomains && !strings.HasPrefix(domain, ".") {
+			domain = "." + domain
+		} else if !includeSubdomains {
+			domain = strings.TrimPrefix(domain, ".")
+		}
+
+		c := &http.Cookie{
+			Domain:   domain,
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+
+	return cookies, scanner.Err()
+}
+
+// WriteNetscapeCookies writes cookies in the Netscape/Mozilla cookies.txt format, HttpOnly cookies are prefixed with #HttpOnly_.
+// A cookie matches subdomains if its Domain field starts with a dot.
+func WriteNetscapeCookies(w io.Writer, cookies []*http.Cookie) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Netscape HTTP Cookie File\n")
+	bw.WriteString("# This file was generated by ghttp! Edit at your own risk.\n\n")
+
+	boolString := func(b bool) string {
+		if b {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	for _, c := range cookies {
+		if c.HttpOnly {
+			bw.WriteString(netscapeHTTPOnlyPrefix)
+		}
+
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			c.Domain, boolString(strings.HasPrefix(c.Domain, ".")), path,
+			boolString(c.Secure), expires, c.Name, c.Value)
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,114 @@
+package ghttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentJar(t *testing.T) {
+	u := mustParseURL("https://www.example.com/a/b")
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	jar := NewPersistentJar()
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "s"},
+		{Name: "token", Value: "t", Domain: "example.com", Path: "/", Expires: expires, Secure: true, HttpOnly: true},
+		{Name: "expired", Value: "e", MaxAge: -1},
+		{Name: "foreign", Value: "f", Domain: "other.com"},
+		{Name: "suffix", Value: "s", Domain: "com"},
+	})
+
+	all := jar.All()
+	if assert.Len(t, all, 2) {
+		assert.Equal(t, ".example.com", all[0].Domain)
+		assert.True(t, all[0].Secure)
+		assert.True(t, all[0].HttpOnly)
+		assert.Equal(t, "www.example.com", all[1].Domain)
+		assert.Equal(t, "/a", all[1].Path)
+		assert.True(t, all[1].Expires.IsZero())
+	}
+	assert.Len(t, jar.Cookies(mustParseURL("https://api.example.com/")), 1)
+
+	for _, format := range []CookieFormat{CookieFormatJSON, CookieFormatNetscape} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, jar.Save(buf, format))
+
+		restored := NewPersistentJar()
+		require.NoError(t, restored.Load(buf, format))
+		if all := restored.All(); assert.Len(t, all, 1) {
+			assert.Equal(t, "token", all[0].Name)
+			assert.Equal(t, ".example.com", all[0].Domain)
+			assert.True(t, expires.Equal(all[0].Expires))
+			assert.True(t, all[0].HttpOnly)
+		}
+		assert.Len(t, restored.Cookies(mustParseURL("https://api.example.com/")), 1)
+		assert.Empty(t, restored.Cookies(mustParseURL("http://api.example.com/")))
+	}
+
+	jar.SetKeepSessionCookies(true)
+	dir, err := ioutil.TempDir("", "ghttp")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "cookies.txt")
+	require.NoError(t, jar.SaveFile(filename, CookieFormatNetscape))
+	restored := NewPersistentJar()
+	require.NoError(t, restored.LoadFile(filename, CookieFormatNetscape))
+	assert.Len(t, restored.All(), 2)
+	assert.Len(t, restored.Cookies(u), 2)
+	assert.Len(t, restored.Cookies(mustParseURL("https://api.example.com/a")), 1)
+
+	restored.Delete(".example.com", "/", "token")
+	if all := restored.All(); assert.Len(t, all, 1) {
+		assert.Equal(t, "session", all[0].Name)
+	}
+	assert.Len(t, restored.Cookies(u), 1)
+
+	restored.Clear()
+	assert.Empty(t, restored.All())
+	assert.Empty(t, restored.Cookies(u))
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "token", Domain: "example.com", Path: "/", MaxAge: -1}})
+	assert.Len(t, jar.All(), 1)
+
+	assert.Error(t, jar.Save(new(bytes.Buffer), CookieFormat(-1)))
+	assert.Error(t, jar.Load(bytes.NewBufferString("example.com\tFALSE\t/"), CookieFormatNetscape))
+}
+
+func TestClient_ImportCookies(t *testing.T) {
+	const cookiesTxt = `# Netscape HTTP Cookie File
+
+.example.com	TRUE	/	TRUE	0	token	t
+#HttpOnly_www.example.com	FALSE	/a	FALSE	4102444800	session	s
+`
+
+	client := New()
+	assert.Equal(t, ErrNilCookieJar, client.ImportCookies(bytes.NewBufferString(cookiesTxt)))
+	assert.Equal(t, ErrNilCookieJar, client.ExportCookies(new(bytes.Buffer)))
+
+	client.EnableSession(newCookieJar())
+	require.NoError(t, client.ImportCookies(bytes.NewBufferString(cookiesTxt)))
+	cookies, err := client.FilterCookies("https://www.example.com/a/b")
+	require.NoError(t, err)
+	assert.Len(t, cookies, 2)
+	cookies, err = client.FilterCookies("https://api.example.com/a/b")
+	require.NoError(t, err)
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, ErrCookieJarNotEnumerable, client.ExportCookies(new(bytes.Buffer)))
+
+	client.EnableSession()
+	require.NoError(t, client.ImportCookies(bytes.NewBufferString(cookiesTxt)))
+	buf := new(bytes.Buffer)
+	require.NoError(t, client.ExportCookies(buf))
+	assert.Contains(t, buf.String(), ".example.com\tTRUE\t/\tTRUE\t0\ttoken\tt\n")
+	assert.Contains(t, buf.String(), "#HttpOnly_www.example.com\tFALSE\t/a\tFALSE\t4102444800\tsession\ts\n")
+
+	assert.Error(t, client.ImportCookies(bytes.NewBufferString("example.com\tFALSE\t/\tFALSE\tx\tk\tv")))
+}
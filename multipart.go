@@ -0,0 +1,620 @@
+package ghttp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultFilename = "file"
+)
+
+type (
+	// Multipart builds a multipart payload whose parts are written in the order they're added.
+	// The payload is streamed, its Content-Length is known if the sizes of all parts are known,
+	// e.g. files, bytes and strings. It can be replayed, e.g. by Retrier, if all parts can be reopened,
+	// e.g. files opened by Open, *bytes.Reader, *strings.Reader, *bytes.Buffer and nested Multipart.
+	Multipart struct {
+		subtype  string
+		boundary string
+		params   map[string]string
+		parts    []*section
+		err      error
+	}
+
+	// Part is a part of a multipart payload, see NewPart.
+	Part struct {
+		Header textproto.MIMEHeader
+		Body   io.Reader
+	}
+
+	// section is a part added to Multipart.
+	section struct {
+		header textproto.MIMEHeader
+		body   *partBody
+	}
+
+	// partBody opens the body of a part, size is -1 if unknown.
+	partBody struct {
+		open       func() (io.ReadCloser, error)
+		size       int64
+		replayable bool
+
+		// offset is the position of a file where the body starts.
+		offset int64
+
+		// detectMIME means the Content-Type of the part should be detected once opened.
+		detectMIME bool
+	}
+
+	multipartReader struct {
+		m      *Multipart
+		next   int
+		cur    io.Reader
+		closer io.Closer
+		err    error
+	}
+
+	errorReader struct {
+		err error
+	}
+)
+
+// NewMultipart returns a new Multipart of multipart/form-data with a random boundary.
+func NewMultipart() *Multipart {
+	return &Multipart{
+		subtype:  "form-data",
+		boundary: multipart.NewWriter(ioutil.Discard).Boundary(),
+	}
+}
+
+// NewMixedMultipart returns a new Multipart of multipart/mixed with a random boundary.
+func NewMixedMultipart() *Multipart {
+	return NewMultipart().SetSubtype("mixed")
+}
+
+// NewRelatedMultipart returns a new Multipart of multipart/related with a random boundary, see RFC 2387.
+// The first part is the root part, whose media type is used as the type parameter unless specified by SetParam.
+func NewRelatedMultipart() *Multipart {
+	return NewMultipart().SetSubtype("related")
+}
+
+// SetParam sets a parameter of the Content-Type of m, e.g. "start" or "type" for multipart/related.
+func (m *Multipart) SetParam(name string, value string) *Multipart {
+	if m.params == nil {
+		m.params = make(map[string]string)
+	}
+	m.params[name] = value
+	return m
+}
+
+// SetSubtype sets the subtype of m, e.g. "mixed", default is "form-data".
+func (m *Multipart) SetSubtype(subtype string) *Multipart {
+	m.subtype = subtype
+	return m
+}
+
+// SetBoundary overrides the random boundary of m.
+// The boundary must be 1-70 characters as defined by RFC 2046.
+func (m *Multipart) SetBoundary(boundary string) *Multipart {
+	if err := multipart.NewWriter(ioutil.Discard).SetBoundary(boundary); err != nil {
+		m.setErr(err)
+		return m
+	}
+
+	m.boundary = boundary
+	return m
+}
+
+// Boundary returns the boundary of m.
+func (m *Multipart) Boundary() string {
+	return m.boundary
+}
+
+// ContentType returns the Content-Type of m, e.g. "multipart/form-data; boundary=xxx".
+func (m *Multipart) ContentType() string {
+	params := make(map[string]string, len(m.params)+1)
+	for k, v := range m.params {
+		params[k] = v
+	}
+	params["boundary"] = m.boundary
+	if m.subtype == "related" && params["type"] == "" && len(m.parts) > 0 {
+		// RFC 2387 requires the type parameter, which is the type of the root part.
+		if mediaType, _, err := mime.ParseMediaType(m.parts[0].header.Get("Content-Type")); err == nil {
+			params["type"] = mediaType
+		}
+	}
+	return mime.FormatMediaType("multipart/"+m.subtype, params)
+}
+
+// Err returns the first error occurred while building m.
+func (m *Multipart) Err() error {
+	return m.err
+}
+
+func (m *Multipart) setErr(err error) {
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+// AddField adds a form field to m.
+func (m *Multipart) AddField(name string, value string) *Multipart {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(name)))
+	return m.AddPart(h, strings.NewReader(value))
+}
+
+// AddFields adds the form fields to m, sorted by key.
+func (m *Multipart) AddFields(form Form) *Multipart {
+	vv, err := form.DecodeStrict()
+	if err != nil {
+		m.setErr(err)
+		return m
+	}
+
+	keys := make([]string, 0, len(vv))
+	for k := range vv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range vv[k] {
+			m.AddField(k, v)
+		}
+	}
+	return m
+}
+
+// AddFile adds a file to m as a form field.
+// The filename defaults to "file" and the MIME is detected by http.DetectContentType if not specified.
+func (m *Multipart) AddFile(name string, file *File) *Multipart {
+	filename := file.Filename
+	if filename == "" {
+		filename = defaultFilename
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition",
+		fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(name), escapeQuotes(filename)))
+	if file.MIME != "" {
+		h.Set("Content-Type", file.MIME)
+	}
+
+	var body io.Reader = file.Body
+	if file.reader != nil {
+		body = file.reader
+	}
+	m.addPart(h, body, file.MIME == "")
+	return m
+}
+
+// AddPart adds a part to m with the header specified, e.g. Content-Type or Content-Disposition.
+func (m *Multipart) AddPart(header textproto.MIMEHeader, body io.Reader) *Multipart {
+	m.addPart(header, body, false)
+	return m
+}
+
+// AddParts adds the parts to m.
+func (m *Multipart) AddParts(parts ...*Part) *Multipart {
+	for _, p := range parts {
+		m.AddPart(p.Header, p.Body)
+	}
+	return m
+}
+
+// AddMultipart adds a nested multipart to m, e.g. multipart/mixed in multipart/form-data.
+// Its Content-Type is set automatically.
+func (m *Multipart) AddMultipart(header textproto.MIMEHeader, nested *Multipart) *Multipart {
+	if nested.err != nil {
+		m.setErr(nested.err)
+		return m
+	}
+
+	h := cloneMIMEHeader(header)
+	h.Set("Content-Type", nested.ContentType())
+	m.parts = append(m.parts, &section{
+		header: h,
+		body: &partBody{
+			open: func() (io.ReadCloser, error) {
+				return nested.newReader(), nil
+			},
+			size:       nested.size(),
+			replayable: nested.replayable(),
+		},
+	})
+	return m
+}
+
+func (m *Multipart) addPart(header textproto.MIMEHeader, body io.Reader, detectMIME bool) {
+	pb, err := newPartBody(body)
+	if err != nil {
+		m.setErr(err)
+		return
+	}
+
+	h := cloneMIMEHeader(header)
+	if detectMIME {
+		if pb.replayable {
+			// Sniff the content now so that the part header, thus the size, is known.
+			if err = sniffPartBody(pb, h); err != nil {
+				m.setErr(err)
+				return
+			}
+		} else {
+			pb.detectMIME = true
+		}
+	}
+
+	m.parts = append(m.parts, &section{
+		header: h,
+		body:   pb,
+	})
+}
+
+func newPartBody(body io.Reader) (*partBody, error) {
+	switch v := body.(type) {
+	case nil:
+		return newBytesPartBody(nil), nil
+	case *File:
+		return newPartBody(v.Body)
+	case *bytes.Buffer:
+		return newBytesPartBody(v.Bytes()), nil
+	case *bytes.Reader:
+		snapshot := *v
+		return &partBody{
+			open: func() (io.ReadCloser, error) {
+				r := snapshot
+				return ioutil.NopCloser(&r), nil
+			},
+			size:       int64(v.Len()),
+			replayable: true,
+		}, nil
+	case *strings.Reader:
+		snapshot := *v
+		return &partBody{
+			open: func() (io.ReadCloser, error) {
+				r := snapshot
+				return ioutil.NopCloser(&r), nil
+			},
+			size:       int64(v.Len()),
+			replayable: true,
+		}, nil
+	case *os.File:
+		fi, err := v.Stat()
+		var offset int64
+		if err == nil && fi.Mode().IsRegular() {
+			offset, err = v.Seek(0, io.SeekCurrent)
+		}
+		if err == nil && fi.Mode().IsRegular() && offset <= fi.Size() {
+			// The file is reopened by name and read from the same offset when replayed.
+			first := v
+			name := v.Name()
+			return &partBody{
+				open: func() (io.ReadCloser, error) {
+					if f := first; f != nil {
+						first = nil
+						return f, nil
+					}
+					f, err := os.Open(name)
+					if err != nil {
+						return nil, err
+					}
+					if _, err = f.Seek(offset, io.SeekStart); err != nil {
+						f.Close()
+						return nil, err
+					}
+					return f, nil
+				},
+				size:       fi.Size() - offset,
+				replayable: true,
+				offset:     offset,
+			}, nil
+		}
+	}
+
+	rc := toReadCloser(body)
+	return &partBody{
+		open: func() (io.ReadCloser, error) {
+			if rc == nil {
+				return nil, errors.New("ghttp: multipart part body already consumed")
+			}
+			r := rc
+			rc = nil
+			return r, nil
+		},
+		size: -1,
+	}, nil
+}
+
+func newBytesPartBody(b []byte) *partBody {
+	return &partBody{
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		},
+		size:       int64(len(b)),
+		replayable: true,
+	}
+}
+
+func sniffPartBody(pb *partBody, h textproto.MIMEHeader) error {
+	rc, err := pb.open()
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, 512)
+	if f, ok := rc.(*os.File); ok {
+		// The body starts at the offset of the file.
+		n, err := f.ReadAt(data, pb.offset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		data = data[:n]
+
+		// Hand the file back for the first open.
+		open := pb.open
+		pb.open = func() (io.ReadCloser, error) {
+			if f != nil {
+				r := f
+				f = nil
+				return r, nil
+			}
+			return open()
+		}
+	} else {
+		n, err := io.ReadFull(rc, data)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		data = data[:n]
+		rc.Close()
+	}
+
+	h.Set("Content-Type", http.DetectContentType(data))
+	return nil
+}
+
+func cloneMIMEHeader(header textproto.MIMEHeader) textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader, len(header))
+	for k, vs := range header {
+		h[k] = append([]string(nil), vs...)
+	}
+	return h
+}
+
+// formatPartHeader formats the header of a part like multipart.Writer.
+func formatPartHeader(h textproto.MIMEHeader) string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(&sb, "%s: %s\r\n", k, v)
+		}
+	}
+	sb.WriteString("\r\n")
+	return sb.String()
+}
+
+func (m *Multipart) delimiter(i int) string {
+	if i == 0 {
+		return "--" + m.boundary + "\r\n"
+	}
+	return "\r\n--" + m.boundary + "\r\n"
+}
+
+func (m *Multipart) closeDelimiter() string {
+	if len(m.parts) == 0 {
+		return "--" + m.boundary + "--\r\n"
+	}
+	return "\r\n--" + m.boundary + "--\r\n"
+}
+
+// size returns the size of the payload, or -1 if unknown.
+func (m *Multipart) size() int64 {
+	n := int64(len(m.closeDelimiter()))
+	for i, p := range m.parts {
+		if p.body.size < 0 || p.body.detectMIME {
+			return -1
+		}
+		n += int64(len(m.delimiter(i))+len(formatPartHeader(p.header))) + p.body.size
+	}
+	return n
+}
+
+func (m *Multipart) replayable() bool {
+	for _, p := range m.parts {
+		if !p.body.replayable {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Multipart) newReader() io.ReadCloser {
+	return &multipartReader{m: m}
+}
+
+// Read implements io.Reader interface.
+func (r *multipartReader) Read(b []byte) (int, error) {
+	for r.err == nil {
+		if r.cur != nil {
+			n, err := r.cur.Read(b)
+			if err == io.EOF {
+				r.closeCurrent()
+				r.cur = nil
+				if n > 0 {
+					return n, nil
+				}
+				continue
+			}
+			return n, err
+		}
+
+		parts := r.m.parts
+		switch {
+		case r.next < len(parts):
+			r.err = r.openPart(r.next)
+		case r.next == len(parts):
+			r.cur = strings.NewReader(r.m.closeDelimiter())
+		default:
+			r.err = io.EOF
+		}
+		r.next++
+	}
+	return 0, r.err
+}
+
+func (r *multipartReader) openPart(i int) error {
+	p := r.m.parts[i]
+	body, err := p.body.open()
+	if err != nil {
+		return err
+	}
+
+	var br io.Reader = body
+	header := p.header
+	if p.body.detectMIME {
+		buf := bufio.NewReader(body)
+		data, _ := buf.Peek(512)
+		header = cloneMIMEHeader(header)
+		header.Set("Content-Type", http.DetectContentType(data))
+		br = buf
+	}
+
+	r.closer = body
+	r.cur = io.MultiReader(strings.NewReader(r.m.delimiter(i)+formatPartHeader(header)), br)
+	return nil
+}
+
+func (r *multipartReader) closeCurrent() {
+	if r.closer != nil {
+		r.closer.Close()
+		r.closer = nil
+	}
+}
+
+// Close implements io.Closer interface.
+func (r *multipartReader) Close() error {
+	r.closeCurrent()
+	return nil
+}
+
+// Read implements io.Reader interface.
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// SetMultipartBody sets multipart payload built by m for the HTTP request.
+func (req *Request) SetMultipartBody(m *Multipart) error {
+	if m.err != nil {
+		return &Error{
+			Op:  "Request.SetMultipartBody",
+			Err: m.err,
+		}
+	}
+
+	req.SetContentType(m.ContentType())
+	req.Body = m.newReader()
+	req.ContentLength = 0
+	req.GetBody = nil
+	if size := m.size(); size >= 0 {
+		req.ContentLength = size
+	}
+	if m.replayable() {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return m.newReader(), nil
+		}
+	}
+	return nil
+}
+
+// WithMultipartBody is a request option to set multipart payload built by m for the HTTP request.
+func WithMultipartBody(m *Multipart) RequestOption {
+	return func(req *Request) error {
+		return req.SetMultipartBody(m)
+	}
+}
+
+// NewPart returns a new Part given its body.
+func NewPart(body io.Reader) *Part {
+	return &Part{
+		Header: make(textproto.MIMEHeader),
+		Body:   body,
+	}
+}
+
+// NewJSONPart returns a new Part of the JSON encoding of v, its Content-Type is "application/json; charset=UTF-8".
+func NewJSONPart(v interface{}) (*Part, error) {
+	b, err := jsonMarshal(v, "", "", false)
+	if err != nil {
+		return nil, &Error{
+			Op:  "NewJSONPart",
+			Err: err,
+		}
+	}
+
+	return NewPart(bytes.NewReader(b)).SetContentType("application/json; charset=UTF-8"), nil
+}
+
+// SetHeader sets the header of p associated with key to value.
+func (p *Part) SetHeader(key string, value string) *Part {
+	p.Header.Set(key, value)
+	return p
+}
+
+// SetContentType sets Content-Type header value of p.
+func (p *Part) SetContentType(contentType string) *Part {
+	return p.SetHeader("Content-Type", contentType)
+}
+
+// SetContentID sets Content-ID header value of p, the angle brackets are added if missing.
+func (p *Part) SetContentID(id string) *Part {
+	if !strings.HasPrefix(id, "<") {
+		id = "<" + id + ">"
+	}
+	return p.SetHeader("Content-ID", id)
+}
+
+// SetMultipartRelated sets multipart/related payload for the HTTP request, the first part is the root part.
+// Use NewRelatedMultipart and SetMultipartBody to control the boundary and parameters.
+func (req *Request) SetMultipartRelated(parts ...*Part) error {
+	return req.SetMultipartBody(NewRelatedMultipart().AddParts(parts...))
+}
+
+// SetMultipartMixed sets multipart/mixed payload for the HTTP request.
+// Use NewMixedMultipart and SetMultipartBody to control the boundary.
+func (req *Request) SetMultipartMixed(parts ...*Part) error {
+	return req.SetMultipartBody(NewMixedMultipart().AddParts(parts...))
+}
+
+// WithMultipartRelated is a request option to set multipart/related payload for the HTTP request.
+func WithMultipartRelated(parts ...*Part) RequestOption {
+	return func(req *Request) error {
+		return req.SetMultipartRelated(parts...)
+	}
+}
+
+// WithMultipartMixed is a request option to set multipart/mixed payload for the HTTP request.
+func WithMultipartMixed(parts ...*Part) RequestOption {
+	return func(req *Request) error {
+		return req.SetMultipartMixed(parts...)
+	}
+}
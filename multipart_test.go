@@ -0,0 +1,238 @@
+package ghttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultipart(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		buf := new(bytes.Buffer)
+		fmt.Fprintf(buf, "%d %v\n", r.ContentLength, r.TransferEncoding)
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			data, _ := ioutil.ReadAll(p)
+			fmt.Fprintf(buf, "%s %s %s %s\n", p.FormName(), p.FileName(), p.Header.Get("Content-Type"), data)
+		}
+		w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	nested := NewMixedMultipart().
+		AddPart(textproto.MIMEHeader{"Content-Type": {"text/plain"}}, strings.NewReader("a")).
+		AddPart(textproto.MIMEHeader{"Content-Type": {"text/plain"}}, bytes.NewBufferString("b"))
+	m := NewMultipart().
+		SetBoundary("ghttp-boundary").
+		AddField("z", "first").
+		AddFile("file1", MustOpen("./testdata/testfile1.txt")).
+		AddFile("file2", FileFromReader(bytes.NewReader([]byte("<p>html</p>")))).
+		AddMultipart(textproto.MIMEHeader{"Content-Disposition": {`form-data; name="files"`}}, nested).
+		AddFields(Form{"b": []string{"1", "2"}, "a": 0})
+	require.NoError(t, m.Err())
+	assert.Equal(t, "ghttp-boundary", m.Boundary())
+	assert.Equal(t, "multipart/form-data; boundary=ghttp-boundary", m.ContentType())
+
+	req, err := NewRequest(MethodPost, ts.URL, WithMultipartBody(m))
+	require.NoError(t, err)
+	require.NotNil(t, req.GetBody)
+
+	first, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(first), req.ContentLength)
+	body, err := req.GetBody()
+	require.NoError(t, err)
+	second, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	// The payload is compatible with mime/multipart.
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	form, err := multipart.NewReader(bytes.NewReader(first), params["boundary"]).ReadForm(1 << 20)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, form.Value["b"])
+
+	req.Body, _ = req.GetBody()
+	data, err := New().Do(req).Text()
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%d []\n", len(first))+
+		"z   first\n"+
+		"file1 testfile1.txt text/plain; charset=utf-8 testfile1.txt\n"+
+		"file2 file text/html; charset=utf-8 <p>html</p>\n"+
+		"files  multipart/mixed; boundary="+nested.Boundary()+" "+
+		"--"+nested.Boundary()+"\r\nContent-Type: text/plain\r\n\r\na\r\n"+
+		"--"+nested.Boundary()+"\r\nContent-Type: text/plain\r\n\r\nb\r\n"+
+		"--"+nested.Boundary()+"--\r\n\n"+
+		"a   0\n"+
+		"b   1\n"+
+		"b   2\n", data)
+
+	// Unknown size is sent chunked and can't be replayed.
+	m = NewMultipart().AddFile("file", FileFromReader(ioutil.NopCloser(strings.NewReader("hello"))))
+	req, err = NewRequest(MethodPost, ts.URL, WithMultipartBody(m))
+	require.NoError(t, err)
+	assert.Nil(t, req.GetBody)
+	data, err = New().Do(req).Text()
+	require.NoError(t, err)
+	assert.Equal(t, "-1 [chunked]\nfile file text/plain; charset=utf-8 hello\n", data)
+
+	// A file is read from its current offset, also when replayed.
+	f, err := os.Open("./testdata/testfile1.txt")
+	require.NoError(t, err)
+	_, err = f.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	m = NewMultipart().SetBoundary("ghttp-boundary").AddPart(nil, f)
+	req, err = NewRequest(MethodPost, ts.URL, WithMultipartBody(m))
+	require.NoError(t, err)
+	first, err = ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	req.Body.Close()
+	assert.EqualValues(t, len(first), req.ContentLength)
+	assert.Equal(t, "--ghttp-boundary\r\n\r\nfile1.txt\r\n--ghttp-boundary--\r\n", string(first))
+	body, err = req.GetBody()
+	require.NoError(t, err)
+	second, err = ioutil.ReadAll(body)
+	require.NoError(t, err)
+	body.Close()
+	assert.Equal(t, first, second)
+
+	// The MIME is detected from the offset as well.
+	tmp, err := ioutil.TempFile("", "ghttp")
+	require.NoError(t, err)
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d<html><body>hi</body></html>")
+	require.NoError(t, err)
+	_, err = tmp.Seek(12, io.SeekStart)
+	require.NoError(t, err)
+	m = NewMultipart().SetBoundary("ghttp-boundary").AddFile("page", FileFromReader(tmp))
+	req, err = NewRequest(MethodPost, ts.URL, WithMultipartBody(m))
+	require.NoError(t, err)
+	first, err = ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	req.Body.Close()
+	assert.EqualValues(t, len(first), req.ContentLength)
+	assert.Contains(t, string(first), "Content-Type: text/html; charset=utf-8\r\n\r\n<html><body>hi</body></html>\r\n")
+	body, err = req.GetBody()
+	require.NoError(t, err)
+	second, err = ioutil.ReadAll(body)
+	require.NoError(t, err)
+	body.Close()
+	assert.Equal(t, first, second)
+
+	// Only the close delimiter is written without parts.
+	req, err = NewRequest(MethodPost, ts.URL, WithMultipartBody(NewMultipart().SetBoundary("ghttp-boundary")))
+	require.NoError(t, err)
+	first, err = ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "--ghttp-boundary--\r\n", string(first))
+	assert.EqualValues(t, len(first), req.ContentLength)
+	_, err = multipart.NewReader(bytes.NewReader(first), "ghttp-boundary").NextPart()
+	assert.Equal(t, io.EOF, err)
+
+	// Errors are surfaced through the request.
+	err = New().Post(ts.URL, WithMultipart(Files{
+		"file": FileFromReader(&dummyBody{s: "hello", errFlag: errRead}),
+	}, nil)).Err()
+	assert.True(t, errors.Is(err, errPermissionDenied))
+
+	err = New().Post(ts.URL, WithMultipart(nil, Form{"invalid": testInvalidVal})).Err()
+	assert.Error(t, err)
+
+	_, err = NewRequest(MethodPost, ts.URL, WithMultipartBody(NewMultipart().SetBoundary("")))
+	assert.Error(t, err)
+	_, err = NewRequest(MethodPost, ts.URL, WithMultipartBody(NewMultipart().AddFields(Form{"invalid": testInvalidVal})))
+	assert.Error(t, err)
+	_, err = NewRequest(MethodPost, ts.URL,
+		WithMultipartBody(NewMultipart().AddMultipart(nil, NewMixedMultipart().SetBoundary(strings.Repeat("x", 71)))))
+	assert.Error(t, err)
+}
+
+func TestRequest_SetMultipartRelated(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		buf := new(bytes.Buffer)
+		fmt.Fprintf(buf, "%s %s %d\n", mediaType, params["type"], r.ContentLength)
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			data, _ := ioutil.ReadAll(p)
+			fmt.Fprintf(buf, "%s %s %s\n", p.Header.Get("Content-ID"), p.Header.Get("Content-Type"), data)
+		}
+		w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	metadata, err := NewJSONPart(H{"name": "file.bin"})
+	require.NoError(t, err)
+	media := NewPart(bytes.NewReader([]byte{0x01, 0x02})).
+		SetContentType("application/octet-stream").
+		SetContentID("media")
+
+	client := New()
+	data, err := client.Post(ts.URL, WithMultipartRelated(metadata, media)).Text()
+	require.NoError(t, err)
+	assert.Regexp(t, `^multipart/related application/json \d+\n`+
+		` application/json; charset=UTF-8 {"name":"file.bin"}\n`+
+		"<media> application/octet-stream \x01\x02\n$", data)
+
+	data, err = client.Post(ts.URL, WithMultipartMixed(
+		NewPart(strings.NewReader("GET /a HTTP/1.1\r\n\r\n")).SetContentType("application/http").SetContentID("<1>"),
+		NewPart(strings.NewReader("GET /b HTTP/1.1\r\n\r\n")).SetContentType("application/http").SetContentID("2"),
+	)).Text()
+	require.NoError(t, err)
+	assert.Regexp(t, `^multipart/mixed  \d+\n`+
+		"<1> application/http GET /a HTTP/1.1\r\n\r\n\n"+
+		"<2> application/http GET /b HTTP/1.1\r\n\r\n\n$", data)
+
+	m := NewRelatedMultipart().
+		SetBoundary("related").
+		SetParam("start", "<root>").
+		SetParam("type", "text/xml").
+		AddParts(NewPart(strings.NewReader("<a/>")).SetContentType("application/xop+xml").SetContentID("root"))
+	assert.Equal(t, `multipart/related; boundary=related; start="<root>"; type="text/xml"`, m.ContentType())
+
+	_, err = NewJSONPart(math.Inf(1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,176 @@
+package ghttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"strconv"
+	"strings"
+)
+
+type (
+	// MultipartReader iterates over the parts of a multipart HTTP response as a stream.
+	MultipartReader struct {
+		mediaType string
+		params    map[string]string
+		reader    *multipart.Reader
+		body      io.Closer
+	}
+
+	// MultipartPart is a part of a multipart HTTP response.
+	// Its body is only valid until the next call to MultipartReader.Next.
+	MultipartPart struct {
+		*multipart.Part
+	}
+
+	// ByteRange is a range of bytes of the selected representation, see RFC 7233 section 4.2.
+	ByteRange struct {
+		// First and Last are the zero-based positions of the first and last bytes, both inclusive.
+		First int64
+		Last  int64
+
+		// Size is the complete length of the representation, or -1 if unknown.
+		Size int64
+	}
+)
+
+// Multipart returns a MultipartReader to iterate over the parts of the multipart HTTP response,
+// e.g. multipart/mixed replies of batch APIs or multipart/byteranges replies of multi-range requests.
+// The caller should close the reader when done.
+func (resp *Response) Multipart() (*MultipartReader, error) {
+	if resp.err != nil {
+		return nil, resp.err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err == nil && !strings.HasPrefix(mediaType, "multipart/") {
+		err = fmt.Errorf("not a multipart response: %s", mediaType)
+	}
+	if err == nil && params["boundary"] == "" {
+		err = fmt.Errorf("no multipart boundary")
+	}
+	if err != nil {
+		return nil, &Error{
+			Op:  "Response.Multipart",
+			Err: err,
+		}
+	}
+
+	var body io.Reader = resp.Body
+	if resp.content != nil {
+		body = bytes.NewReader(resp.content)
+	}
+	return &MultipartReader{
+		mediaType: mediaType,
+		params:    params,
+		reader:    multipart.NewReader(body, params["boundary"]),
+		body:      resp.Body,
+	}, nil
+}
+
+// MediaType returns the media type of the multipart HTTP response, e.g. "multipart/mixed".
+func (mr *MultipartReader) MediaType() string {
+	return mr.mediaType
+}
+
+// Param returns the named parameter of the Content-Type, e.g. "type" of multipart/related.
+func (mr *MultipartReader) Param(name string) string {
+	return mr.params[name]
+}
+
+// Next returns the next part, or io.EOF if there are no more parts.
+// The body of a part with "Content-Transfer-Encoding: quoted-printable" is decoded transparently,
+// and the header is removed.
+func (mr *MultipartReader) Next() (*MultipartPart, error) {
+	p, err := mr.reader.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	return &MultipartPart{Part: p}, nil
+}
+
+// Close closes the HTTP response body.
+func (mr *MultipartReader) Close() error {
+	return mr.body.Close()
+}
+
+// Content reads the body of p.
+func (p *MultipartPart) Content() ([]byte, error) {
+	return ioutil.ReadAll(p)
+}
+
+// JSON decodes the JSON-encoded body of p into v.
+func (p *MultipartPart) JSON(v interface{}) error {
+	return json.NewDecoder(p).Decode(v)
+}
+
+// ContentID returns Content-ID header value of p without the angle brackets.
+func (p *MultipartPart) ContentID() string {
+	id := p.Header.Get("Content-ID")
+	return strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">")
+}
+
+// ByteRange returns the byte range of p given its Content-Range header, which is present in
+// the parts of multipart/byteranges.
+func (p *MultipartPart) ByteRange() (*ByteRange, error) {
+	return parseContentRange(p.Header.Get("Content-Range"))
+}
+
+// ByteRange returns the byte range of a 206 (Partial Content) HTTP response with a single part
+// given its Content-Range header. Use Multipart for multipart/byteranges replies.
+func (resp *Response) ByteRange() (*ByteRange, error) {
+	if resp.err != nil {
+		return nil, resp.err
+	}
+
+	return parseContentRange(resp.Header.Get("Content-Range"))
+}
+
+// Len returns the number of bytes of br.
+func (br *ByteRange) Len() int64 {
+	return br.Last - br.First + 1
+}
+
+func parseContentRange(s string) (*ByteRange, error) {
+	invalid := func() (*ByteRange, error) {
+		return nil, &Error{
+			Op:  "parseContentRange",
+			Err: fmt.Errorf("invalid Content-Range %q", s),
+		}
+	}
+
+	const unit = "bytes "
+	if !strings.HasPrefix(s, unit) {
+		return invalid()
+	}
+
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return invalid()
+	}
+
+	rangeSpec, sizeSpec := strings.TrimSpace(s[len(unit):i]), strings.TrimSpace(s[i+1:])
+	j := strings.IndexByte(rangeSpec, '-')
+	if j < 0 {
+		return invalid()
+	}
+
+	br := &ByteRange{Size: -1}
+	var err error
+	if br.First, err = strconv.ParseInt(rangeSpec[:j], 10, 64); err != nil || br.First < 0 {
+		return invalid()
+	}
+	if br.Last, err = strconv.ParseInt(rangeSpec[j+1:], 10, 64); err != nil || br.Last < br.First {
+		return invalid()
+	}
+	if sizeSpec != "*" {
+		if br.Size, err = strconv.ParseInt(sizeSpec, 10, 64); err != nil || br.Size <= br.Last {
+			return invalid()
+		}
+	}
+	return br, nil
+}
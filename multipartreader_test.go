@@ -0,0 +1,160 @@
+package ghttp
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponse_Multipart(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/batch":
+			mw := multipart.NewWriter(w)
+			w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+			for i, body := range []string{`{"id":1,"name":"foo"}`, `{"id":2,"name":"bar"}`} {
+				h := make(textproto.MIMEHeader)
+				h.Set("Content-Type", "application/json")
+				h.Set("Content-ID", "<item"+string('1'+rune(i))+">")
+				pw, _ := mw.CreatePart(h)
+				pw.Write([]byte(body))
+			}
+			mw.Close()
+		case "/file":
+			content := strings.Repeat("0123456789", 10)
+			http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(content))
+		case "/qp":
+			w.Header().Set("Content-Type", "multipart/mixed; boundary=b")
+			w.Write([]byte("--b\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\ncaf=C3=A9\r\n--b--\r\n"))
+		case "/noboundary":
+			w.Header().Set("Content-Type", "multipart/mixed")
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+		}
+	}))
+	defer ts.Close()
+
+	client := New()
+
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	for _, prefetch := range []bool{false, true} {
+		resp := client.Get(ts.URL + "/batch")
+		if prefetch {
+			resp.Prefetch()
+		}
+		mr, err := resp.Multipart()
+		require.NoError(t, err)
+		assert.Equal(t, "multipart/mixed", mr.MediaType())
+
+		var items []item
+		var ids []string
+		for {
+			p, err := mr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			var v item
+			require.NoError(t, p.JSON(&v))
+			items = append(items, v)
+			ids = append(ids, p.ContentID())
+		}
+		assert.NoError(t, mr.Close())
+		assert.Equal(t, []item{{1, "foo"}, {2, "bar"}}, items)
+		assert.Equal(t, []string{"item1", "item2"}, ids)
+	}
+
+	resp := client.Get(ts.URL+"/file", WithHeaders(Headers{"Range": "bytes=0-4,50-59"}))
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	mr, err := resp.Multipart()
+	require.NoError(t, err)
+	defer mr.Close()
+	assert.Equal(t, "multipart/byteranges", mr.MediaType())
+
+	var ranges []ByteRange
+	var contents []string
+	for {
+		p, err := mr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		br, err := p.ByteRange()
+		require.NoError(t, err)
+		b, err := p.Content()
+		require.NoError(t, err)
+		assert.Equal(t, br.Len(), int64(len(b)))
+		ranges = append(ranges, *br)
+		contents = append(contents, string(b))
+	}
+	assert.Equal(t, []ByteRange{{0, 4, 100}, {50, 59, 100}}, ranges)
+	assert.Equal(t, []string{"01234", "0123456789"}, contents)
+
+	resp = client.Get(ts.URL+"/file", WithHeaders(Headers{"Range": "bytes=10-19"}))
+	br, err := resp.ByteRange()
+	require.NoError(t, err)
+	assert.Equal(t, &ByteRange{10, 19, 100}, br)
+	_, err = resp.Multipart()
+	assert.Error(t, err)
+
+	mr, err = client.Get(ts.URL + "/qp").Multipart()
+	require.NoError(t, err)
+	p, err := mr.Next()
+	require.NoError(t, err)
+	b, err := p.Content()
+	require.NoError(t, err)
+	assert.Equal(t, "café", string(b))
+	assert.Empty(t, p.Header.Get("Content-Transfer-Encoding"))
+	mr.Close()
+
+	_, err = client.Get(ts.URL + "/noboundary").Multipart()
+	assert.Error(t, err)
+
+	_, err = client.Get(ts.URL + "/json").Multipart()
+	assert.Error(t, err)
+
+	resp = client.Get("http://\x7f")
+	_, err = resp.Multipart()
+	assert.Error(t, err)
+	_, err = resp.ByteRange()
+	assert.Error(t, err)
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		input string
+		want  *ByteRange
+	}{
+		{"bytes 0-49/1000", &ByteRange{0, 49, 1000}},
+		{"bytes 500-999/*", &ByteRange{500, 999, -1}},
+		{"bytes */1000", nil},
+		{"bytes 10-5/1000", nil},
+		{"bytes 0-1000/1000", nil},
+		{"bytes 0-49", nil},
+		{"items 0-49/1000", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		br, err := parseContentRange(test.input)
+		if test.want == nil {
+			assert.Error(t, err, test.input)
+			continue
+		}
+		if assert.NoError(t, err, test.input) {
+			assert.Equal(t, test.want, br)
+		}
+	}
+
+}
@@ -0,0 +1,173 @@
+package ghttp
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+type (
+	// NetrcEntry represents a machine entry of a netrc file.
+	// The default entry has an empty Machine.
+	NetrcEntry struct {
+		Machine  string
+		Login    string
+		Password string
+		Account  string
+	}
+
+	netrc struct {
+		path    string
+		entries []*NetrcEntry
+	}
+)
+
+// DefaultNetrcPath returns the default path of the netrc file, it's $NETRC if set,
+// otherwise .netrc in the home directory (_netrc on Windows).
+func DefaultNetrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(home, name)
+}
+
+// ParseNetrc parses a netrc file and returns its entries.
+// Macro definitions are skipped.
+func ParseNetrc(r io.Reader) ([]*NetrcEntry, error) {
+	var (
+		entries []*NetrcEntry
+		current *NetrcEntry
+		tokens  []string
+	)
+
+	scanner := bufio.NewScanner(r)
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// A macro definition ends with an empty line.
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		tokens = strings.Fields(line)
+		for i := 0; i < len(tokens); i++ {
+			next := func() string {
+				if i+1 < len(tokens) {
+					i++
+					return tokens[i]
+				}
+				return ""
+			}
+
+			switch tokens[i] {
+			case "machine":
+				current = &NetrcEntry{Machine: next()}
+				entries = append(entries, current)
+			case "default":
+				current = &NetrcEntry{}
+				entries = append(entries, current)
+			case "login":
+				if current != nil {
+					current.Login = next()
+				}
+			case "password":
+				if current != nil {
+					current.Password = next()
+				}
+			case "account":
+				if current != nil {
+					current.Account = next()
+				}
+			case "macdef":
+				inMacro = true
+				i = len(tokens)
+			}
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+func loadNetrc(path string) (*netrc, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries, err := ParseNetrc(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &netrc{
+		path:    path,
+		entries: entries,
+	}, nil
+}
+
+// lookup returns the entry matches host, or the default entry if any.
+func (n *netrc) lookup(host string) *NetrcEntry {
+	var defaultEntry *NetrcEntry
+	for _, e := range n.entries {
+		if e.Machine == "" {
+			if defaultEntry == nil {
+				defaultEntry = e
+			}
+			continue
+		}
+
+		if strings.EqualFold(e.Machine, host) {
+			return e
+		}
+	}
+	return defaultEntry
+}
+
+// EnableNetrc makes c look up credentials from a netrc file given an optional path,
+// and sets basic authentication for requests to the matching hosts that don't have Authorization header.
+// If the path not specified, default is DefaultNetrcPath.
+// If there is an error while reading the netrc file, it will be ignored.
+func (c *Client) EnableNetrc(path ...string) *Client {
+	var _path string
+	if len(path) > 0 {
+		_path = path[0]
+	} else {
+		_path = DefaultNetrcPath()
+	}
+
+	c.netrc, _ = loadNetrc(_path)
+	return c
+}
+
+func (c *Client) applyNetrc(req *Request) {
+	if c.netrc == nil || req.Header.Get("Authorization") != "" {
+		return
+	}
+
+	e := c.netrc.lookup(req.URL.Hostname())
+	if e == nil || e.Login == "" {
+		return
+	}
+
+	req.SetBasicAuth(e.Login, e.Password)
+	// Let the exported curl command use --netrc instead of the credentials.
+	req.netrcPath = c.netrc.path
+}
@@ -0,0 +1,103 @@
+package ghttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testNetrc = `# comment
+machine example.com login admin password secret account team
+macdef init
+cd /pub
+bin
+
+machine 127.0.0.1
+	login user
+	password pass
+default login anonymous password guest@example.com
+`
+)
+
+func TestParseNetrc(t *testing.T) {
+	entries, err := ParseNetrc(strings.NewReader(testNetrc))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, &NetrcEntry{Machine: "example.com", Login: "admin", Password: "secret", Account: "team"}, entries[0])
+	assert.Equal(t, &NetrcEntry{Machine: "127.0.0.1", Login: "user", Password: "pass"}, entries[1])
+	assert.Equal(t, &NetrcEntry{Login: "anonymous", Password: "guest@example.com"}, entries[2])
+
+	n := &netrc{entries: entries}
+	assert.Equal(t, entries[0], n.lookup("EXAMPLE.com"))
+	assert.Equal(t, entries[2], n.lookup("google.com"))
+}
+
+func TestDefaultNetrcPath(t *testing.T) {
+	os.Setenv("NETRC", "/tmp/netrc")
+	defer os.Unsetenv("NETRC")
+	assert.Equal(t, "/tmp/netrc", DefaultNetrcPath())
+
+	os.Unsetenv("NETRC")
+	assert.True(t, strings.HasSuffix(DefaultNetrcPath(), "netrc"))
+}
+
+func TestClient_EnableNetrc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghttp")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "netrc")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testNetrc), 0600))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		w.Write([]byte(username + ":" + password))
+	}))
+	defer ts.Close()
+
+	client := New().EnableNetrc(path)
+	req, err := NewRequest(http.MethodGet, ts.URL)
+	require.NoError(t, err)
+	resp := client.Do(req)
+	data, err := resp.Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "user:pass", data)
+	}
+
+	cmd, err := req.Export()
+	if assert.NoError(t, err) {
+		assert.Contains(t, cmd, "--netrc-file '"+path+"'")
+		assert.NotContains(t, cmd, "Authorization")
+	}
+
+	cmd, err = GenCURLCommand(resp.Request)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, cmd, "--netrc")
+		assert.Contains(t, cmd, "Authorization")
+	}
+
+	data, err = client.
+		Get(ts.URL,
+			WithBasicAuth("admin", "admin"),
+		).
+		Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "admin:admin", data)
+	}
+
+	data, err = New().
+		EnableNetrc(filepath.Join(dir, "not-exist")).
+		Get(ts.URL).
+		Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, ":", data)
+	}
+}
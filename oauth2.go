@@ -0,0 +1,282 @@
+package ghttp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOAuth2ExpiryDelta = 10 * time.Second
+)
+
+type (
+	// OAuth2Config specifies how to obtain OAuth2 access tokens from the token endpoint.
+	// If RefreshToken is specified, ghttp will use the refresh-token grant, otherwise the client-credentials grant.
+	OAuth2Config struct {
+		TokenURL     string
+		ClientID     string
+		ClientSecret string
+		Scopes       []string
+		RefreshToken string
+
+		// EndpointParams specifies additional parameters for requests to the token endpoint.
+		EndpointParams Params
+
+		// ExpiryDelta determines how earlier a token should be considered expired than its actual expiration time,
+		// default is 10s.
+		ExpiryDelta time.Duration
+	}
+
+	// OAuth2Token represents the credentials used to authorize the requests.
+	OAuth2Token struct {
+		AccessToken  string    `json:"access_token"`
+		TokenType    string    `json:"token_type,omitempty"`
+		RefreshToken string    `json:"refresh_token,omitempty"`
+		ExpiresIn    int64     `json:"expires_in,omitempty"`
+		Expiry       time.Time `json:"expiry,omitempty"`
+	}
+
+	// OAuth2Error represents an error response of the token endpoint, see RFC 6749 section 5.2.
+	OAuth2Error struct {
+		Code        string `json:"error"`
+		Description string `json:"error_description,omitempty"`
+		URI         string `json:"error_uri,omitempty"`
+	}
+
+	// OAuth2 fetches OAuth2 access tokens and caches them until shortly before expiry.
+	// It implements Authenticator interface, use it with Client.UseOAuth2 or Client.UseAuthenticator.
+	// It's concurrent safe, only one token request would be sent when concurrent requests find the token expired,
+	// the others wait for it until their own contexts are done.
+	OAuth2 struct {
+		config   *OAuth2Config
+		client   *Client
+		mu       sync.Mutex
+		token    *OAuth2Token
+		fetching *oauth2Fetch
+	}
+
+	// oauth2Fetch is an in-flight token request shared by the concurrent callers.
+	oauth2Fetch struct {
+		done  chan struct{}
+		token *OAuth2Token
+		err   error
+
+		// aborted reports whether the token request is interrupted by the context of its sender.
+		aborted bool
+	}
+)
+
+// Error implements error interface.
+func (e *OAuth2Error) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("ghttp: oauth2: %s", e.Code)
+	}
+
+	return fmt.Sprintf("ghttp: oauth2: %s: %s", e.Code, e.Description)
+}
+
+// Type returns the normalized token type, default is "Bearer".
+func (t *OAuth2Token) Type() string {
+	switch {
+	case t.TokenType == "", strings.EqualFold(t.TokenType, "bearer"):
+		return "Bearer"
+	case strings.EqualFold(t.TokenType, "mac"):
+		return "MAC"
+	case strings.EqualFold(t.TokenType, "basic"):
+		return "Basic"
+	}
+	return t.TokenType
+}
+
+func (t *OAuth2Token) valid(expiryDelta time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+
+	return t.Expiry.IsZero() || time.Now().Add(expiryDelta).Before(t.Expiry)
+}
+
+// NewOAuth2 returns a new OAuth2 given its config and an optional client for requests to the token endpoint.
+// If the client not specified, ghttp will use a new Client.
+// Note: Do not use the Client which o is used by for requests to the token endpoint, otherwise it will deadlock.
+func NewOAuth2(config *OAuth2Config, client ...*Client) *OAuth2 {
+	o := &OAuth2{
+		config: config,
+	}
+	if len(client) > 0 {
+		o.client = client[0]
+	} else {
+		o.client = New()
+	}
+	return o
+}
+
+// SetToken sets the cached token of o, e.g. a token persisted before.
+func (o *OAuth2) SetToken(token *OAuth2Token) *OAuth2 {
+	o.mu.Lock()
+	o.token = token
+	o.mu.Unlock()
+	return o
+}
+
+// Token returns the cached token if it's still valid, otherwise fetches a new one from the token endpoint.
+func (o *OAuth2) Token(ctx context.Context) (*OAuth2Token, error) {
+	return o.getToken(ctx, func(token *OAuth2Token) bool {
+		return token.valid(o.expiryDelta())
+	})
+}
+
+// Refresh forces o to fetch a new token from the token endpoint.
+// If a token request is in flight, it waits for the token of that request instead.
+func (o *OAuth2) Refresh(ctx context.Context) (*OAuth2Token, error) {
+	return o.getToken(ctx, func(*OAuth2Token) bool {
+		return false
+	})
+}
+
+// refresh is like Refresh, but it does nothing if the cached token has been refreshed by others,
+// i.e. it's no longer the stale one.
+func (o *OAuth2) refresh(ctx context.Context, stale string) (*OAuth2Token, error) {
+	return o.getToken(ctx, func(token *OAuth2Token) bool {
+		return token.valid(o.expiryDelta()) && token.Type()+" "+token.AccessToken != stale
+	})
+}
+
+// getToken returns the cached token if reuse reports true for it, otherwise fetches a new one.
+// Only the first caller sends the token request, the others wait for it until their contexts are done.
+// If the token request is interrupted by the context of its sender, the waiting callers try again on their own.
+func (o *OAuth2) getToken(ctx context.Context, reuse func(token *OAuth2Token) bool) (*OAuth2Token, error) {
+	for {
+		o.mu.Lock()
+		if reuse(o.token) {
+			token := o.token
+			o.mu.Unlock()
+			return token, nil
+		}
+
+		f := o.fetching
+		if f == nil {
+			break
+		}
+		o.mu.Unlock()
+
+		select {
+		case <-f.done:
+			if !f.aborted {
+				return f.token, f.err
+			}
+		case <-ctx.Done():
+			return nil, &Error{
+				Op:  "OAuth2.Token",
+				Err: ctx.Err(),
+			}
+		}
+	}
+
+	f := &oauth2Fetch{done: make(chan struct{})}
+	o.fetching = f
+	refreshToken := o.config.RefreshToken
+	if o.token != nil && o.token.RefreshToken != "" {
+		refreshToken = o.token.RefreshToken
+	}
+	o.mu.Unlock()
+
+	f.token, f.err = o.fetchToken(ctx, refreshToken)
+	f.aborted = f.err != nil && ctx.Err() != nil
+
+	o.mu.Lock()
+	if f.err == nil {
+		o.token = f.token
+	}
+	o.fetching = nil
+	o.mu.Unlock()
+	close(f.done)
+
+	return f.token, f.err
+}
+
+func (o *OAuth2) expiryDelta() time.Duration {
+	if o.config.ExpiryDelta > 0 {
+		return o.config.ExpiryDelta
+	}
+
+	return defaultOAuth2ExpiryDelta
+}
+
+// fetchToken requests a new token from the token endpoint, it's called without holding o.mu.
+func (o *OAuth2) fetchToken(ctx context.Context, refreshToken string) (*OAuth2Token, error) {
+	form := Form{}
+	for k, v := range o.config.EndpointParams {
+		form[k] = v
+	}
+
+	if refreshToken != "" {
+		form.Set("grant_type", "refresh_token").Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(o.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.config.Scopes, " "))
+	}
+
+	token := new(OAuth2Token)
+	err := o.client.
+		Post(o.config.TokenURL,
+			WithForm(form),
+			WithBasicAuth(o.config.ClientID, o.config.ClientSecret),
+			WithHeaders(Headers{
+				"Accept": "application/json",
+			}),
+			WithContext(ctx),
+		).
+		EnsureStatus2xx(new(OAuth2Error)).
+		JSON(token)
+	if err != nil {
+		return nil, &Error{
+			Op:  "OAuth2.Token",
+			Err: err,
+		}
+	}
+	if token.AccessToken == "" {
+		return nil, &Error{
+			Op:  "OAuth2.Token",
+			Err: fmt.Errorf("server response missing access_token"),
+		}
+	}
+
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// UseOAuth2 makes c authorize every request with the token provided by o, it's a shortcut for UseAuthenticator.
+// If a request gets a 401 response, c would force o to refresh the token and retry it once.
+func (c *Client) UseOAuth2(o *OAuth2) *Client {
+	return c.UseAuthenticator(o)
+}
+
+// Authenticate implements Authenticator interface.
+// It sets the Authorization header of req using the cached token, the token may be refreshed if it's expired.
+func (o *OAuth2) Authenticate(req *Request) error {
+	token, err := o.Token(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", token.Type()+" "+token.AccessToken)
+	return nil
+}
+
+// Challenge implements Authenticator interface.
+// It forces o to refresh the token which req used, then replays req once.
+func (o *OAuth2) Challenge(req *Request, _ *Response) (bool, error) {
+	_, err := o.refresh(req.Context(), req.Header.Get("Authorization"))
+	return err == nil, err
+}
@@ -0,0 +1,194 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuth2(t *testing.T) {
+	var (
+		counter uint64
+		current atomic.Value
+	)
+	current.Store("")
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "id" || clientSecret != "secret" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+
+		r.ParseForm()
+		switch r.PostForm.Get("grant_type") {
+		case "client_credentials":
+			if r.PostForm.Get("scope") != "read write" || r.PostForm.Get("audience") != "api" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") != "refresh" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		n := atomic.AddUint64(&counter, 1)
+		token := fmt.Sprintf("token%d", n)
+		current.Store(token)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"%s","token_type":"bearer","expires_in":3600}`, token)
+	}))
+	defer tokenServer.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+current.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	o := NewOAuth2(&OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+		EndpointParams: Params{
+			"audience": "api",
+		},
+	})
+	client := New().UseOAuth2(o)
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.Get(ts.URL).EnsureStatusOk().Err())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, uint64(1), atomic.LoadUint64(&counter))
+
+	// The server revokes the token, ghttp should refresh it and retry once.
+	current.Store("revoked")
+	err := client.
+		Post(ts.URL,
+			WithText("hello"),
+		).
+		EnsureStatusOk().
+		Err()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&counter))
+
+	token, err := o.Token(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "token2", token.AccessToken)
+		assert.Equal(t, "Bearer", token.Type())
+		assert.True(t, token.Expiry.After(time.Now()))
+	}
+
+	o = NewOAuth2(&OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		RefreshToken: "refresh",
+	})
+	token, err = o.Refresh(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "token3", token.AccessToken)
+		assert.Equal(t, "refresh", token.RefreshToken)
+	}
+
+	o = NewOAuth2(&OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "id",
+		ClientSecret: "wrong",
+	})
+	err = New().UseAuthenticator(o).Get(ts.URL).Err()
+	var oe *OAuth2Error
+	if assert.True(t, errors.As(err, &oe)) {
+		assert.Equal(t, "invalid_client", oe.Code)
+		assert.Equal(t, "ghttp: oauth2: invalid_client: bad credentials", oe.Error())
+	}
+}
+
+func TestOAuth2_SetToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	o := NewOAuth2(&OAuth2Config{}).SetToken(&OAuth2Token{
+		AccessToken: "hello",
+		TokenType:   "mac",
+	})
+	data, err := New().UseAuthenticator(o).Get(ts.URL).Text()
+	require.NoError(t, err)
+	assert.Equal(t, "MAC hello", data)
+}
+
+func TestOAuth2_Wait(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token%d"}`, n)
+	}))
+	defer tokenServer.Close()
+
+	o := NewOAuth2(&OAuth2Config{
+		TokenURL: tokenServer.URL,
+	})
+
+	leaderCtx, leaderCancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error)
+	go func() {
+		_, err := o.Token(leaderCtx)
+		leaderDone <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// A waiting caller stops if its context is done, even though the token request is still in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := o.Token(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// A waiting caller sends the token request again if the sender gives up.
+	followerDone := make(chan *OAuth2Token)
+	go func() {
+		token, err := o.Token(context.Background())
+		assert.NoError(t, err)
+		followerDone <- token
+	}()
+	time.Sleep(50 * time.Millisecond)
+	leaderCancel()
+	assert.True(t, errors.Is(<-leaderDone, context.Canceled))
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	token := <-followerDone
+	if assert.NotNil(t, token) {
+		assert.Equal(t, "token2", token.AccessToken)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
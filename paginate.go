@@ -0,0 +1,459 @@
+package ghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	paginateByLink = iota
+	paginateByCursor
+	paginateByOffset
+)
+
+var (
+	// ErrMaxPages can be used when a Paginator reaches its max number of pages.
+	ErrMaxPages = errors.New("ghttp: max pages reached")
+
+	// ErrCrossOriginLink can be used when a Paginator is asked to follow a link to another origin.
+	ErrCrossOriginLink = errors.New("ghttp: cross-origin link")
+)
+
+type (
+	// Link is a web link of the Link HTTP header, see RFC 8288.
+	Link struct {
+		URL string
+
+		// Rel holds the relation types, e.g. "next", "prev" or "last".
+		Rel    []string
+		Params map[string]string
+	}
+
+	// Page is a page fetched by a Paginator.
+	Page struct {
+		// Number is the one-based position of the page.
+		Number   int
+		Response *Response
+
+		// Body holds the decoded JSON object of the response body, it's nil if the body is not an object.
+		Body H
+
+		// Items holds the elements of the page, it's the decoded JSON array of the response body,
+		// or the one extracted from Body.
+		Items []interface{}
+	}
+
+	// Paginator iterates over the pages of a paginated API.
+	// By default it follows the "next" links of the Link HTTP header, like GitHub APIs.
+	// A link to an origin other than the one of the first page is refused with ErrCrossOriginLink,
+	// since the requests carry the credentials of the client and the opts.
+	// Use UseCursor or UseOffset for cursor-based or offset-based APIs.
+	Paginator struct {
+		client  *Client
+		method  string
+		url     string
+		opts    []RequestOption
+		ctx     context.Context
+		limiter Limiter
+
+		mode     int
+		param    string
+		cursor   func(body H) string
+		limit    int
+		itemsKey string
+		maxPages int
+
+		origin *neturl.URL
+		next   string
+		offset int
+		page   *Page
+		done   bool
+		err    error
+	}
+)
+
+// Paginate returns a Paginator to iterate over the pages of a paginated API starting from url.
+// The opts apply to the request of every page.
+func (c *Client) Paginate(url string, opts ...RequestOption) *Paginator {
+	return &Paginator{
+		client: c,
+		method: http.MethodGet,
+		url:    url,
+		opts:   opts,
+		ctx:    context.Background(),
+		next:   url,
+	}
+}
+
+// UseCursor makes p pass the cursor to the next page in the query parameter param.
+// The cursor is extracted from the decoded JSON object of the current page, an empty cursor means the last page.
+func (p *Paginator) UseCursor(param string, cursor func(body H) string) *Paginator {
+	if cursor == nil {
+		p.err = &Error{
+			Op:  "Paginator.UseCursor",
+			Err: errors.New("nil cursor func"),
+		}
+		return p
+	}
+
+	p.mode = paginateByCursor
+	p.param = param
+	p.cursor = cursor
+	return p
+}
+
+// UseOffset makes p pass the number of items fetched so far in the query parameter param.
+// limit is the page size the API is asked for, a page has fewer items than it means the last page.
+// If limit is not positive, an empty page means the last page.
+func (p *Paginator) UseOffset(param string, limit int) *Paginator {
+	p.mode = paginateByOffset
+	p.param = param
+	p.limit = limit
+	return p
+}
+
+// SetItemsKey specifies the key of the items in the decoded JSON object of a page, e.g. "data".
+// It's not required if the API returns a JSON array.
+func (p *Paginator) SetItemsKey(key string) *Paginator {
+	p.itemsKey = key
+	return p
+}
+
+// SetMaxPages limits the number of pages to fetch, ErrMaxPages is reported if there are more.
+// n <= 0 means no limit.
+func (p *Paginator) SetMaxPages(n int) *Paginator {
+	p.maxPages = n
+	return p
+}
+
+// SetContext sets the context shared by the requests of all pages.
+func (p *Paginator) SetContext(ctx context.Context) *Paginator {
+	p.ctx = ctx
+	return p
+}
+
+// UseRateLimiter limits the requests of the pages, in addition to the rate-limiter of the client.
+func (p *Paginator) UseRateLimiter(limiter Limiter) *Paginator {
+	p.limiter = limiter
+	return p
+}
+
+// Next fetches the next page, it returns false if there are no more pages or an error occurs.
+// Use Page to get the page, and Err to check the error.
+func (p *Paginator) Next() bool {
+	if p.done || p.err != nil {
+		return false
+	}
+
+	number := 1
+	if p.page != nil {
+		number = p.page.Number + 1
+	}
+	if p.maxPages > 0 && number > p.maxPages {
+		p.err = ErrMaxPages
+		return false
+	}
+
+	page, err := p.fetch(number)
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	p.page = page
+	p.advance()
+	return true
+}
+
+// Page returns the current page.
+func (p *Paginator) Page() *Page {
+	return p.page
+}
+
+// Err returns the error occurred during the iteration.
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// ForEachItem calls fn for each item of the remaining pages, it stops if fn returns an error.
+func (p *Paginator) ForEachItem(fn func(item interface{}) error) error {
+	for p.Next() {
+		for _, item := range p.page.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+	return p.err
+}
+
+// All returns the items of the remaining pages.
+func (p *Paginator) All() ([]interface{}, error) {
+	var items []interface{}
+	err := p.ForEachItem(func(item interface{}) error {
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+func (p *Paginator) fetch(number int) (*Page, error) {
+	req, err := p.newRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.ctx.Err(); err != nil {
+		return nil, wrapRequestError(req, 0, err)
+	}
+
+	if p.limiter != nil && !p.limiter.Allow(req.Request) {
+		if err = p.limiter.Wait(p.ctx); err != nil {
+			return nil, wrapRequestError(req, 0, &RequestError{
+				Kind: ErrRateLimited,
+				Err:  err,
+			})
+		}
+	}
+
+	resp := p.client.Do(req).EnsureStatus2xx().Prefetch()
+	if err = resp.Err(); err != nil {
+		return nil, err
+	}
+	if p.origin == nil {
+		// The URL of the first page is resolved against the base URL of the client by now.
+		p.origin = req.URL
+	}
+
+	page := &Page{
+		Number:   number,
+		Response: resp,
+	}
+	if err = page.decode(p.itemsKey); err != nil {
+		return nil, &Error{
+			Op:  "Paginator.Next",
+			Err: err,
+		}
+	}
+	return page, nil
+}
+
+func (p *Paginator) newRequest() (*Request, error) {
+	req, err := NewRequest(p.method, p.url, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	req.SetContext(p.ctx)
+
+	switch p.mode {
+	case paginateByLink:
+		if p.page != nil {
+			u, err := neturl.Parse(p.next)
+			if err != nil {
+				return nil, &Error{
+					Op:  "neturl.Parse",
+					Err: err,
+				}
+			}
+			req.URL = u
+			req.Host = u.Host
+			if !sameOrigin(u, p.origin) {
+				return nil, wrapRequestError(req, 0, ErrCrossOriginLink)
+			}
+		}
+	case paginateByCursor:
+		if p.page != nil {
+			query := req.URL.Query()
+			query.Set(p.param, p.next)
+			req.URL.RawQuery = query.Encode()
+		}
+	case paginateByOffset:
+		query := req.URL.Query()
+		query.Set(p.param, strconv.Itoa(p.offset))
+		req.URL.RawQuery = query.Encode()
+	}
+	return req, nil
+}
+
+// advance determines the next page given the current one.
+func (p *Paginator) advance() {
+	page := p.page
+	switch p.mode {
+	case paginateByLink:
+		p.next = ""
+		for _, link := range page.Response.Links() {
+			if link.hasRel("next") {
+				p.next = link.URL
+				break
+			}
+		}
+	case paginateByCursor:
+		p.next = ""
+		if page.Body != nil {
+			p.next = p.cursor(page.Body)
+		}
+	case paginateByOffset:
+		p.offset += len(page.Items)
+		p.done = len(page.Items) == 0 || p.limit > 0 && len(page.Items) < p.limit
+		return
+	}
+	p.done = p.next == ""
+}
+
+// sameOrigin reports whether u and origin have the same scheme, host and port.
+func sameOrigin(u *neturl.URL, origin *neturl.URL) bool {
+	return strings.EqualFold(u.Scheme, origin.Scheme) &&
+		strings.EqualFold(u.Hostname(), origin.Hostname()) &&
+		originPort(u) == originPort(origin)
+}
+
+func originPort(u *neturl.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if strings.EqualFold(u.Scheme, "https") {
+		return "443"
+	}
+	return "80"
+}
+
+func (page *Page) decode(itemsKey string) error {
+	content := bytes.TrimSpace(page.Response.content)
+	if len(content) == 0 {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		page.Body = v
+		if itemsKey != "" {
+			page.Items = page.Body.GetSlice(itemsKey)
+		}
+	case []interface{}:
+		page.Items = v
+	}
+	return nil
+}
+
+// Links returns the web links of the Link HTTP header, relative URLs are resolved against the request URL.
+func (resp *Response) Links() []*Link {
+	if resp.err != nil {
+		return nil
+	}
+
+	links := ParseLinkHeader(strings.Join(resp.Header["Link"], ","))
+	if resp.Request != nil && resp.Request.URL != nil {
+		for _, link := range links {
+			if u, err := resp.Request.URL.Parse(link.URL); err == nil {
+				link.URL = u.String()
+			}
+		}
+	}
+	return links
+}
+
+// ParseLinkHeader parses the value of the Link HTTP header, see RFC 8288.
+// Malformed links are skipped.
+func ParseLinkHeader(header string) []*Link {
+	var links []*Link
+	for s := header; s != ""; {
+		s = strings.TrimLeft(s, " \t,")
+		if !strings.HasPrefix(s, "<") {
+			// Skip to the next link.
+			i := strings.Index(s, ",")
+			if i < 0 {
+				break
+			}
+			s = s[i+1:]
+			continue
+		}
+
+		end := strings.IndexByte(s, '>')
+		if end < 0 {
+			break
+		}
+		link := &Link{
+			URL:    strings.TrimSpace(s[1:end]),
+			Params: make(map[string]string),
+		}
+		s = s[end+1:]
+
+		// Parse the parameters until the next link.
+		for {
+			s = strings.TrimLeft(s, " \t")
+			if !strings.HasPrefix(s, ";") {
+				break
+			}
+			s = strings.TrimLeft(s[1:], " \t")
+
+			i := strings.IndexAny(s, "=;,")
+			if i < 0 {
+				i = len(s)
+			}
+			name := strings.ToLower(strings.TrimSpace(s[:i]))
+			s = s[i:]
+
+			var value string
+			if strings.HasPrefix(s, "=") {
+				value, s = parseLinkParamValue(strings.TrimLeft(s[1:], " \t"))
+			}
+			if name == "" {
+				continue
+			}
+			if _, ok := link.Params[name]; !ok {
+				// Occurrences after the first one must be ignored.
+				link.Params[name] = value
+			}
+		}
+
+		link.Rel = strings.Fields(strings.ToLower(link.Params["rel"]))
+		links = append(links, link)
+	}
+	return links
+}
+
+func parseLinkParamValue(s string) (value string, rest string) {
+	if !strings.HasPrefix(s, `"`) {
+		i := strings.IndexAny(s, ";,")
+		if i < 0 {
+			i = len(s)
+		}
+		return strings.TrimSpace(s[:i]), s[i:]
+	}
+
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+		case '"':
+			return sb.String(), s[i+1:]
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), ""
+}
+
+func (link *Link) hasRel(rel string) bool {
+	for _, r := range link.Rel {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
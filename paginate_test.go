@@ -0,0 +1,206 @@
+package ghttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader(`<https://api.github.com/repositories/1/issues?page=2>; rel="next", ` +
+		`<https://api.github.com/repositories/1/issues?page=5>; rel="last"; title="Last, \"final\" page", ` +
+		`<https://example.com/a>; rel="prev start"; rel=ignored, malformed; rel=next, ` +
+		`</relative>;rel=alternate;type=text/html`)
+	require.Len(t, links, 4)
+	assert.Equal(t, "https://api.github.com/repositories/1/issues?page=2", links[0].URL)
+	assert.Equal(t, []string{"next"}, links[0].Rel)
+	assert.Equal(t, []string{"last"}, links[1].Rel)
+	assert.Equal(t, `Last, "final" page`, links[1].Params["title"])
+	assert.Equal(t, []string{"prev", "start"}, links[2].Rel)
+	assert.Equal(t, "/relative", links[3].URL)
+	assert.Equal(t, []string{"alternate"}, links[3].Rel)
+	assert.Equal(t, "text/html", links[3].Params["type"])
+
+	assert.Empty(t, ParseLinkHeader(""))
+	assert.Empty(t, ParseLinkHeader("<https://example.com"))
+}
+
+func TestPaginator(t *testing.T) {
+	const total = 25
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		query := r.URL.Query()
+		size, _ := strconv.Atoi(query.Get("per_page"))
+		var start int
+		switch r.URL.Path {
+		case "/link":
+			page, _ := strconv.Atoi(query.Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			start = (page - 1) * size
+			if start+size < total {
+				w.Header().Set("Link", fmt.Sprintf(`</link?page=%d&per_page=%d>; rel="next"`, page+1, size))
+			}
+		case "/cursor":
+			start, _ = strconv.Atoi(query.Get("after"))
+		case "/offset":
+			start, _ = strconv.Atoi(query.Get("offset"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		items := []int{}
+		for i := start; i < start+size && i < total; i++ {
+			items = append(items, i)
+		}
+		if r.URL.Path == "/link" {
+			json.NewEncoder(w).Encode(items)
+			return
+		}
+		body := H{"data": items}
+		if r.URL.Path == "/cursor" && start+size < total {
+			body["next_cursor"] = strconv.Itoa(start + size)
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer ts.Close()
+
+	client := New().SetBaseURL(ts.URL)
+	sequence := func(n int) []interface{} {
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i] = float64(i)
+		}
+		return items
+	}
+
+	p := client.Paginate("/link", WithQuery(Params{"per_page": 10}))
+	var numbers []int
+	for p.Next() {
+		numbers = append(numbers, p.Page().Number)
+	}
+	assert.NoError(t, p.Err())
+	assert.Equal(t, []int{1, 2, 3}, numbers)
+	assert.Len(t, p.Page().Items, 5)
+	assert.False(t, p.Next())
+
+	items, err := client.Paginate("/link", WithQuery(Params{"per_page": 10})).All()
+	require.NoError(t, err)
+	assert.Equal(t, sequence(total), items)
+
+	items, err = client.
+		Paginate("/cursor", WithQuery(Params{"per_page": 10})).
+		UseCursor("after", func(body H) string { return body.GetString("next_cursor") }).
+		SetItemsKey("data").
+		All()
+	require.NoError(t, err)
+	assert.Equal(t, sequence(total), items)
+
+	hits = 0
+	items, err = client.
+		Paginate("/offset", WithQuery(Params{"per_page": 5})).
+		UseOffset("offset", 5).
+		SetItemsKey("data").
+		All()
+	require.NoError(t, err)
+	assert.Equal(t, sequence(total), items)
+	assert.Equal(t, 6, hits)
+
+	hits = 0
+	items, err = client.
+		Paginate("/offset", WithQuery(Params{"per_page": 5})).
+		UseOffset("offset", 0).
+		SetItemsKey("data").
+		All()
+	require.NoError(t, err)
+	assert.Len(t, items, total)
+	assert.Equal(t, 6, hits)
+
+	items, err = client.Paginate("/link", WithQuery(Params{"per_page": 10})).SetMaxPages(2).All()
+	assert.True(t, errors.Is(err, ErrMaxPages))
+	assert.Len(t, items, 20)
+
+	var count int
+	stop := errors.New("stop")
+	err = client.Paginate("/link", WithQuery(Params{"per_page": 10})).ForEachItem(func(item interface{}) error {
+		count++
+		if count == 12 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Paginate("/link").SetContext(ctx).All()
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, IsCanceled(err))
+
+	limiter := NewRegexpLimiter(rate.NewLimiter(rate.Every(time.Minute), 1))
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	items, err = client.
+		Paginate("/link", WithQuery(Params{"per_page": 10})).
+		UseRateLimiter(limiter).
+		SetContext(ctx).
+		All()
+	assert.True(t, IsRateLimited(err))
+	assert.Len(t, items, 10)
+
+	_, err = client.Paginate("/notfound").All()
+	assert.True(t, IsBadStatus(err))
+
+	p = client.Paginate("/cursor").UseCursor("after", nil)
+	assert.False(t, p.Next())
+	assert.Error(t, p.Err())
+}
+
+func TestPaginator_CrossOriginLink(t *testing.T) {
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("Authorization")
+		w.Write([]byte("[]"))
+	}))
+	defer other.Close()
+
+	var next string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next))
+		}
+		w.Write([]byte("[1]"))
+	}))
+	defer ts.Close()
+
+	client := New().UseAuthenticator(NewBearerAuthenticator("secret"))
+
+	next = ts.URL + "/?page=2"
+	items, err := client.Paginate(ts.URL).All()
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	next = other.URL + "/?page=2"
+	p := client.Paginate(ts.URL)
+	assert.True(t, p.Next())
+	assert.False(t, p.Next())
+	assert.True(t, errors.Is(p.Err(), ErrCrossOriginLink))
+	assert.Empty(t, leaked)
+
+	assert.True(t, sameOrigin(mustParseURL("https://Example.com/a"), mustParseURL("https://example.com:443/b")))
+	assert.False(t, sameOrigin(mustParseURL("http://example.com"), mustParseURL("https://example.com")))
+	assert.False(t, sameOrigin(mustParseURL("https://example.com:8443"), mustParseURL("https://example.com")))
+}
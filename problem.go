@@ -0,0 +1,111 @@
+package ghttp
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+)
+
+const (
+	// MIMEProblemJSON is the media type of RFC 7807 problem details in JSON format.
+	MIMEProblemJSON = "application/problem+json"
+)
+
+var problemMembers = []string{"type", "title", "status", "detail", "instance"}
+
+type (
+	// Problem represents problem details for HTTP APIs, see RFC 7807.
+	// Members not defined by RFC 7807 are kept in Extensions.
+	Problem struct {
+		Type       string `json:"type,omitempty"`
+		Title      string `json:"title,omitempty"`
+		Status     int    `json:"status,omitempty"`
+		Detail     string `json:"detail,omitempty"`
+		Instance   string `json:"instance,omitempty"`
+		Extensions H      `json:"-"`
+	}
+
+	problem Problem
+)
+
+// Error implements error interface.
+func (p *Problem) Error() string {
+	title := p.Title
+	if title == "" {
+		title = http.StatusText(p.Status)
+	}
+
+	switch {
+	case title == "":
+		title = p.Detail
+	case p.Detail != "":
+		title += ": " + p.Detail
+	}
+	return "ghttp: " + title
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface.
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var (
+		v problem
+		h H
+	)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return err
+	}
+
+	for _, k := range problemMembers {
+		delete(h, k)
+	}
+	if len(h) > 0 {
+		v.Extensions = h
+	}
+
+	*p = Problem(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	h := make(H, len(p.Extensions)+len(problemMembers))
+	for k, v := range p.Extensions {
+		h[k] = v
+	}
+
+	b, err := json.Marshal(problem(p))
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &h); err != nil {
+		return nil, err
+	}
+
+	return jsonMarshal(h, "", "", false)
+}
+
+// IsProblem reports whether the HTTP response body is problem details in JSON format.
+func (resp *Response) IsProblem() bool {
+	if resp.err != nil {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == MIMEProblemJSON
+}
+
+// Problem decodes the HTTP response body and unmarshals its problem details.
+// If the HTTP response is not a problem, the returned *Problem will be nil.
+func (resp *Response) Problem() (*Problem, error) {
+	if !resp.IsProblem() {
+		return nil, resp.err
+	}
+
+	p := new(Problem)
+	if err := resp.JSON(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
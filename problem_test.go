@@ -0,0 +1,79 @@
+package ghttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testProblem = `{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.","status":403,"detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc","balance":30}`
+)
+
+func TestProblem_Error(t *testing.T) {
+	assert.Equal(t, "ghttp: Forbidden", (&Problem{Status: http.StatusForbidden}).Error())
+	assert.Equal(t, "ghttp: Forbidden: out of credit", (&Problem{Status: http.StatusForbidden, Detail: "out of credit"}).Error())
+	assert.Equal(t, "ghttp: out of credit", (&Problem{Detail: "out of credit"}).Error())
+	assert.Equal(t, "ghttp: Bad: out of credit", (&Problem{Title: "Bad", Detail: "out of credit"}).Error())
+}
+
+func TestProblem_JSON(t *testing.T) {
+	p := new(Problem)
+	err := json.Unmarshal([]byte(testProblem), p)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/probs/out-of-credit", p.Type)
+	assert.Equal(t, "You do not have enough credit.", p.Title)
+	assert.Equal(t, http.StatusForbidden, p.Status)
+	assert.Equal(t, "Your current balance is 30, but that costs 50.", p.Detail)
+	assert.Equal(t, "/account/12345/msgs/abc", p.Instance)
+	assert.Equal(t, 30, p.Extensions.GetNumber("balance").Int())
+
+	b, err := json.Marshal(p)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, testProblem, string(b))
+	}
+
+	err = json.Unmarshal([]byte(`{"status":"403"}`), p)
+	assert.Error(t, err)
+}
+
+func TestResponse_Problem(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/problem" {
+			w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(testProblem))
+		}
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp := client.Get(ts.URL + "/problem")
+	require.True(t, resp.IsProblem())
+
+	p, err := resp.Problem()
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusForbidden, p.Status)
+	}
+
+	err = client.
+		Get(ts.URL + "/problem").
+		EnsureStatusOk().
+		Err()
+	assert.EqualError(t, err, "ghttp: bad status (403 Forbidden): Your current balance is 30, but that costs 50.")
+	var _p *Problem
+	if assert.True(t, errors.As(err, &_p)) {
+		assert.Equal(t, "/account/12345/msgs/abc", _p.Instance)
+	}
+
+	resp = client.Get(ts.URL)
+	assert.False(t, resp.IsProblem())
+	p, err = resp.Problem()
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
@@ -1,20 +1,21 @@
 package ghttp
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/xml"
-	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
-	"mime/multipart"
 	"net/http"
 	"net/http/httputil"
-	"net/textproto"
+	"sort"
 	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v4"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
 // Common HTTP methods.
@@ -35,6 +36,12 @@ type (
 	Request struct {
 		*http.Request
 		retrier *Retrier
+
+		// netrcPath is the path of the netrc file which the credentials come from, set by Client.
+		netrcPath string
+
+		// err records the first error occurred by the chainable setters, it's reported by Client.Do.
+		err error
 	}
 
 	// RequestOption provides a convenient way to setup Request.
@@ -124,8 +131,22 @@ func (req *Request) SetHost(host string) *Request {
 
 // SetHeaders sets headers for the HTTP request.
 // It replaces any existing values.
+// A header of unsupported type makes the request fail when it's sent.
 func (req *Request) SetHeaders(headers Headers) *Request {
-	for k, vs := range headers.Decode() {
+	req.setErr(req.setHeaders(headers))
+	return req
+}
+
+func (req *Request) setHeaders(headers Headers) error {
+	vv, err := headers.DecodeStrict()
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetHeaders",
+			Err: err,
+		}
+	}
+
+	for k, vs := range vv {
 		k = http.CanonicalHeaderKey(k)
 		if k == "Host" && len(vs) > 0 {
 			req.SetHost(vs[0])
@@ -133,7 +154,7 @@ func (req *Request) SetHeaders(headers Headers) *Request {
 			req.Header[k] = vs
 		}
 	}
-	return req
+	return nil
 }
 
 // SetContentType sets Content-Type header value for the HTTP request.
@@ -142,6 +163,12 @@ func (req *Request) SetContentType(contentType string) *Request {
 	return req
 }
 
+// SetAccept sets Accept header value for the HTTP request.
+func (req *Request) SetAccept(accept string) *Request {
+	req.Header.Set("Accept", accept)
+	return req
+}
+
 // SetUserAgent sets User-Agent header value for the HTTP request.
 func (req *Request) SetUserAgent(userAgent string) *Request {
 	req.Header.Set("User-Agent", userAgent)
@@ -162,13 +189,27 @@ func (req *Request) SetReferer(referer string) *Request {
 
 // SetQuery sets query parameters for the HTTP request.
 // It replaces any existing values.
+// A query parameter of unsupported type makes the request fail when it's sent.
 func (req *Request) SetQuery(params Params) *Request {
+	req.setErr(req.setQuery(params))
+	return req
+}
+
+func (req *Request) setQuery(params Params) error {
+	vv, err := params.DecodeStrict()
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetQuery",
+			Err: err,
+		}
+	}
+
 	query := req.URL.Query()
-	for k, vs := range params.Decode() {
+	for k, vs := range vv {
 		query[k] = vs
 	}
 	req.URL.RawQuery = query.Encode()
-	return req
+	return nil
 }
 
 // SetContent sets bytes payload for the HTTP request.
@@ -185,12 +226,37 @@ func (req *Request) SetText(text string) *Request {
 }
 
 // SetForm sets form payload for the HTTP request.
+// A form field of unsupported type makes the request fail when it's sent.
 func (req *Request) SetForm(form Form) *Request {
-	req.SetContentType("application/x-www-form-urlencoded")
-	req.SetBody(strings.NewReader(form.URLEncode(true)))
+	req.setErr(req.setForm(form))
 	return req
 }
 
+func (req *Request) setForm(form Form) error {
+	data, err := form.URLEncodeStrict(true)
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetForm",
+			Err: err,
+		}
+	}
+
+	req.SetContentType("application/x-www-form-urlencoded")
+	req.SetBody(strings.NewReader(data))
+	return nil
+}
+
+// Err returns the first error occurred by the chainable setters of req, e.g. SetHeaders, SetQuery and SetForm.
+func (req *Request) Err() error {
+	return req.err
+}
+
+func (req *Request) setErr(err error) {
+	if req.err == nil {
+		req.err = err
+	}
+}
+
 // SetJSON sets JSON payload for the HTTP request.
 func (req *Request) SetJSON(data interface{}, escapeHTML bool) error {
 	b, err := jsonMarshal(data, "", "", escapeHTML)
@@ -221,84 +287,109 @@ func (req *Request) SetXML(data interface{}) error {
 	return nil
 }
 
-var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+// SetMsgPack sets MessagePack payload for the HTTP request.
+// If Accept header is not specified, it will be set to the same MIME type.
+func (req *Request) SetMsgPack(data interface{}) error {
+	b, err := msgpack.Marshal(data)
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetMsgPack",
+			Err: err,
+		}
+	}
 
-func escapeQuotes(s string) string {
-	return quoteEscaper.Replace(s)
+	req.SetContentType("application/msgpack")
+	req.setDefaultAccept("application/msgpack")
+	req.SetBody(bytes.NewReader(b))
+	return nil
 }
 
-func setMultipartFiles(mw *multipart.Writer, files Files) error {
-	const (
-		fileFormat      = `form-data; name="%s"; filename="%s"`
-		defaultFilename = "file"
-	)
-
-	var (
-		part io.Writer
-		err  error
-	)
-	for k, v := range files {
-		filename := v.Filename
-		if filename == "" {
-			filename = defaultFilename
+// SetCBOR sets CBOR payload for the HTTP request.
+// If Accept header is not specified, it will be set to the same MIME type.
+func (req *Request) SetCBOR(data interface{}) error {
+	b, err := cbor.Marshal(data)
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetCBOR",
+			Err: err,
 		}
+	}
 
-		r := bufio.NewReader(v)
-		mime := v.MIME
-		if mime == "" {
-			data, _ := r.Peek(512)
-			mime = http.DetectContentType(data)
-		}
+	req.SetContentType("application/cbor")
+	req.setDefaultAccept("application/cbor")
+	req.SetBody(bytes.NewReader(b))
+	return nil
+}
 
-		h := make(textproto.MIMEHeader)
-		h.Set("Content-Disposition",
-			fmt.Sprintf(fileFormat, escapeQuotes(k), escapeQuotes(filename)))
-		h.Set("Content-Type", mime)
-		part, err = mw.CreatePart(h)
-		if err != nil {
-			return fmt.Errorf("can't create multipart section for form (%s=@%s): %s", k, filename, err.Error())
+// SetProtobuf sets Protocol Buffers payload for the HTTP request given an optional content type.
+// If the content type not specified, default is application/x-protobuf, you may prefer application/protobuf.
+// If Accept header is not specified, it will be set to the same MIME type.
+func (req *Request) SetProtobuf(msg proto.Message, contentType ...string) error {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetProtobuf",
+			Err: err,
 		}
+	}
 
-		_, err = io.Copy(part, r)
-		if err != nil {
-			return fmt.Errorf("can't read file of form (%s=@%s): %s", k, filename, err.Error())
-		}
+	_contentType := "application/x-protobuf"
+	if len(contentType) > 0 {
+		_contentType = contentType[0]
+	}
+	req.SetContentType(_contentType)
+	req.setDefaultAccept(_contentType)
+	req.SetBody(bytes.NewReader(b))
+	return nil
+}
 
-		v.Close()
+// SetProtobufJSON sets the canonical JSON encoding of a Protocol Buffers message as payload for the HTTP request.
+// It's typically used for gRPC-gateway or Twirp JSON endpoints.
+func (req *Request) SetProtobufJSON(msg proto.Message) error {
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetProtobufJSON",
+			Err: err,
+		}
 	}
 
+	req.SetContentType("application/json")
+	req.SetBody(bytes.NewReader(b))
 	return nil
 }
 
-func setMultipartForm(mw *multipart.Writer, form Form) {
-	for k, vs := range form.Decode() {
-		for _, v := range vs {
-			mw.WriteField(k, v)
-		}
+func (req *Request) setDefaultAccept(accept string) {
+	if req.Header.Get("Accept") == "" {
+		req.SetAccept(accept)
 	}
 }
 
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
 // SetMultipart sets multipart payload for the HTTP request.
+// The files go first and then the form fields, both sorted by key. See Multipart for more control.
 func (req *Request) SetMultipart(files Files, form Form) *Request {
-	pr, pw := io.Pipe()
-	mw := multipart.NewWriter(pw)
-	go func() {
-		defer pw.Close()
-		defer mw.Close()
-
-		err := setMultipartFiles(mw, files)
-		if err != nil {
-			log.Printf("ghttp [Request.SetMultipart]: %s", err.Error())
-			return
-		}
-
-		if len(form) > 0 {
-			setMultipartForm(mw, form)
-		}
-	}()
+	m := NewMultipart()
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m.AddFile(k, files[k])
+	}
+	m.AddFields(form)
 
-	req.SetContentType(mw.FormDataContentType())
-	req.SetBody(pr)
+	if err := req.SetMultipartBody(m); err != nil {
+		// Surface the error once the request is sent.
+		req.SetContentType(m.ContentType())
+		req.SetBody(&errorReader{err: err})
+	}
 	return req
 }
 
@@ -328,7 +419,11 @@ func (req *Request) SetBearerToken(token string) *Request {
 }
 
 // SetContext sets context for the HTTP request.
+// The header order set by SetOrderedHeaders is kept.
 func (req *Request) SetContext(ctx context.Context) *Request {
+	if order := headerOrder(req.Request); order != nil && ctx.Value(headerOrderContextKey{}) == nil {
+		ctx = context.WithValue(ctx, headerOrderContextKey{}, order)
+	}
 	req.Request = req.Request.WithContext(ctx)
 	return req
 }
@@ -341,12 +436,18 @@ func (req *Request) SetRetry(retrier *Retrier) *Request {
 
 // Dump returns the HTTP/1.x wire representation of req.
 func (req *Request) Dump(withBody bool) ([]byte, error) {
+	if headerOrder(req.Request) != nil {
+		return dumpOrderedRequest(req.Request, withBody)
+	}
+
 	return httputil.DumpRequestOut(req.Request, withBody)
 }
 
 // Export converts req to CURL command line.
+// If the credentials of req come from the netrc file of the client which sent it,
+// it emits --netrc (or --netrc-file) instead of the secrets.
 func (req *Request) Export() (string, error) {
-	return GenCURLCommand(req.Request)
+	return genCURLCommand(req.Request, req.netrcPath)
 }
 
 // WithBody is a request option to set body for the HTTP request.
@@ -369,8 +470,7 @@ func WithHost(host string) RequestOption {
 // It replaces any existing values.
 func WithHeaders(headers Headers) RequestOption {
 	return func(req *Request) error {
-		req.SetHeaders(headers)
-		return nil
+		return req.setHeaders(headers)
 	}
 }
 
@@ -382,6 +482,14 @@ func WithContentType(contentType string) RequestOption {
 	}
 }
 
+// WithAccept is a request option to set Accept header value for the HTTP request.
+func WithAccept(accept string) RequestOption {
+	return func(req *Request) error {
+		req.SetAccept(accept)
+		return nil
+	}
+}
+
 // WithUserAgent is a request option to set User-Agent header value for the HTTP request.
 func WithUserAgent(userAgent string) RequestOption {
 	return func(req *Request) error {
@@ -410,8 +518,7 @@ func WithReferer(referer string) RequestOption {
 // It replaces any existing values.
 func WithQuery(params Params) RequestOption {
 	return func(req *Request) error {
-		req.SetQuery(params)
-		return nil
+		return req.setQuery(params)
 	}
 }
 
@@ -434,8 +541,7 @@ func WithText(text string) RequestOption {
 // WithForm is a request option to set form payload for the HTTP request.
 func WithForm(form Form) RequestOption {
 	return func(req *Request) error {
-		req.SetForm(form)
-		return nil
+		return req.setForm(form)
 	}
 }
 
@@ -453,6 +559,35 @@ func WithXML(data interface{}) RequestOption {
 	}
 }
 
+// WithMsgPack is a request option to set MessagePack payload for the HTTP request.
+func WithMsgPack(data interface{}) RequestOption {
+	return func(req *Request) error {
+		return req.SetMsgPack(data)
+	}
+}
+
+// WithCBOR is a request option to set CBOR payload for the HTTP request.
+func WithCBOR(data interface{}) RequestOption {
+	return func(req *Request) error {
+		return req.SetCBOR(data)
+	}
+}
+
+// WithProtobuf is a request option to set Protocol Buffers payload for the HTTP request given an optional content type.
+func WithProtobuf(msg proto.Message, contentType ...string) RequestOption {
+	return func(req *Request) error {
+		return req.SetProtobuf(msg, contentType...)
+	}
+}
+
+// WithProtobufJSON is a request option to set the canonical JSON encoding of a Protocol Buffers message
+// as payload for the HTTP request.
+func WithProtobufJSON(msg proto.Message) RequestOption {
+	return func(req *Request) error {
+		return req.SetProtobufJSON(msg)
+	}
+}
+
 // WithMultipart is a request option sets multipart payload for the HTTP request.
 func WithMultipart(files Files, form Form) RequestOption {
 	return func(req *Request) error {
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
+	"io"
 	"io/ioutil"
 	"math"
 	"net/http"
@@ -14,6 +16,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func TestNewRequest(t *testing.T) {
@@ -348,6 +353,181 @@ func TestRequest_SetXML(t *testing.T) {
 	}
 }
 
+func TestRequest_SetMsgPack(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Header().Set("X-Accept", r.Header.Get("Accept"))
+		io.Copy(w, r.Body)
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp := client.
+		Post(ts.URL,
+			WithMsgPack(math.Inf(1)),
+		)
+	assert.NoError(t, resp.Err())
+
+	resp = client.
+		Post(ts.URL,
+			WithMsgPack(func() {}),
+		)
+	assert.Error(t, resp.Err())
+
+	resp = client.
+		Post(ts.URL,
+			WithMsgPack(H{
+				"msg": "hi",
+				"num": 2019,
+			}),
+		).
+		EnsureStatusOk()
+	require.NoError(t, resp.Err())
+	assert.Equal(t, "application/msgpack", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "application/msgpack", resp.Header.Get("X-Accept"))
+
+	h := make(H)
+	err := resp.MsgPack(&h)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hi", h.GetString("msg"))
+		assert.Equal(t, 2019, h.GetNumber("num").Int())
+	}
+
+	resp = client.
+		Post(ts.URL,
+			WithAccept("*/*"),
+			WithMsgPack(H{}),
+		)
+	require.NoError(t, resp.Err())
+	assert.Equal(t, "*/*", resp.Header.Get("X-Accept"))
+}
+
+func TestRequest_SetCBOR(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Header().Set("X-Accept", r.Header.Get("Accept"))
+		io.Copy(w, r.Body)
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp := client.
+		Post(ts.URL,
+			WithCBOR(make(chan int)),
+		)
+	assert.Error(t, resp.Err())
+
+	resp = client.
+		Post(ts.URL,
+			WithCBOR(H{
+				"msg": "hi",
+				"num": 2019,
+				"obj": H{
+					"nums": []int{1, 2, 3},
+				},
+			}),
+		).
+		EnsureStatusOk()
+	require.NoError(t, resp.Err())
+	assert.Equal(t, "application/cbor", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "application/cbor", resp.Header.Get("X-Accept"))
+
+	h := make(H)
+	err := resp.CBOR(&h)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hi", h.GetString("msg"))
+		assert.Equal(t, 2019, h.GetNumber("num").Int())
+		assert.Equal(t, []Number{1, 2, 3}, h.GetH("obj").GetNumberSlice("nums"))
+	}
+}
+
+func TestRequest_SetProtobuf(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Header().Set("X-Accept", r.Header.Get("Accept"))
+		io.Copy(w, r.Body)
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp := client.
+		Post(ts.URL,
+			WithProtobuf(&structpb.Value{
+				Kind: &structpb.Value_NumberValue{NumberValue: math.Inf(1)},
+			}),
+		)
+	assert.NoError(t, resp.Err())
+
+	resp = client.
+		Post(ts.URL,
+			WithProtobuf(wrapperspb.String(string([]byte{0xff}))),
+		)
+	assert.Error(t, resp.Err())
+
+	resp = client.
+		Post(ts.URL,
+			WithProtobuf(wrapperspb.String("hello")),
+		).
+		EnsureStatusOk()
+	require.NoError(t, resp.Err())
+	assert.Equal(t, "application/x-protobuf", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "application/x-protobuf", resp.Header.Get("X-Accept"))
+
+	msg := new(wrapperspb.StringValue)
+	err := resp.Protobuf(msg)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello", msg.GetValue())
+	}
+
+	resp = client.
+		Post(ts.URL,
+			WithProtobuf(wrapperspb.String("hello"), "application/protobuf"),
+		).
+		EnsureStatusOk()
+	require.NoError(t, resp.Err())
+	assert.Equal(t, "application/protobuf", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "application/protobuf", resp.Header.Get("X-Accept"))
+	err = resp.Protobuf(msg)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello", msg.GetValue())
+	}
+}
+
+func TestRequest_SetProtobufJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		io.Copy(w, r.Body)
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp := client.
+		Post(ts.URL,
+			WithProtobufJSON(wrapperspb.String(string([]byte{0xff}))),
+		)
+	assert.Error(t, resp.Err())
+
+	resp = client.
+		Post(ts.URL,
+			WithProtobufJSON(durationpb.New(1500*time.Millisecond)),
+		).
+		EnsureStatusOk().
+		Prefetch()
+	require.NoError(t, resp.Err())
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	data, err := resp.Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"1.500s"`, data)
+	}
+
+	msg := new(durationpb.Duration)
+	err = resp.ProtobufJSON(msg)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1500*time.Millisecond, msg.AsDuration())
+	}
+}
+
 func TestRequest_SetMultipart(t *testing.T) {
 	// For Charles
 	// client := New()
@@ -364,9 +544,7 @@ func TestRequest_SetMultipart(t *testing.T) {
 				}).SetFilename("dummyBody"),
 			}, nil)).
 		JSON(resp)
-	if assert.NoError(t, err) {
-		assert.Empty(t, resp.Files.GetString("file"))
-	}
+	assert.True(t, errors.Is(err, errPermissionDenied))
 
 	files := Files{
 		"file1": MustOpen("./testdata/testfile1.txt"),
@@ -444,3 +622,35 @@ func TestRequest_SetContext(t *testing.T) {
 		)
 	assert.Error(t, resp.Err())
 }
+
+func TestRequest_SetValuesError(t *testing.T) {
+	invalid := Values{
+		"invalid": testInvalidVal,
+	}
+
+	_, err := NewRequest(MethodGet, "https://httpbin.org/get", WithQuery(invalid))
+	assert.Error(t, err)
+
+	_, err = NewRequest(MethodGet, "https://httpbin.org/get", WithHeaders(invalid))
+	assert.Error(t, err)
+
+	_, err = NewRequest(MethodPost, "https://httpbin.org/post", WithForm(invalid))
+	assert.Error(t, err)
+
+	req, err := NewRequest(MethodGet, "https://httpbin.org/get", WithQuery(Params{
+		"filter": Params{"status": "open"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "filter%5Bstatus%5D=open", req.URL.RawQuery)
+
+	// The chainable setters record the first error, which is reported by Client.Do.
+	req, err = NewRequest(MethodPost, "https://httpbin.org/post")
+	require.NoError(t, err)
+	req.SetQuery(Params{"k": "v"}).SetHeaders(invalid).SetForm(invalid)
+	assert.Equal(t, "k=v", req.URL.RawQuery)
+	var e *Error
+	if assert.True(t, errors.As(req.Err(), &e)) {
+		assert.Equal(t, "Request.SetHeaders", e.Op)
+	}
+	assert.True(t, errors.Is(New().Do(req).Err(), req.Err()))
+}
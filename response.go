@@ -6,11 +6,22 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/http/httputil"
 	"os"
+	"strings"
 
+	"github.com/vmihailenco/msgpack/v4"
 	"golang.org/x/text/encoding"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	protojsonUnmarshalOptions = protojson.UnmarshalOptions{
+		DiscardUnknown: true,
+	}
 )
 
 type (
@@ -19,6 +30,9 @@ type (
 		*http.Response
 		content []byte
 		err     error
+
+		// attempts is the number of attempts made to get resp.
+		attempts int
 	}
 
 	// AfterResponseHook specifies an after response hook.
@@ -104,6 +118,58 @@ func (resp *Response) XML(v interface{}) error {
 	return xml.NewDecoder(resp.Body).Decode(v)
 }
 
+// MsgPack decodes the HTTP response body and unmarshals its MessagePack-encoded data into v.
+// v must be a pointer, it can be *H as well.
+func (resp *Response) MsgPack(v interface{}) error {
+	if resp.err != nil {
+		return resp.err
+	}
+
+	if resp.content != nil {
+		return msgpack.Unmarshal(resp.content, v)
+	}
+	defer resp.Body.Close()
+
+	return msgpack.NewDecoder(resp.Body).Decode(v)
+}
+
+// CBOR decodes the HTTP response body and unmarshals its CBOR-encoded data into v.
+// v must be a pointer, it can be *H as well.
+func (resp *Response) CBOR(v interface{}) error {
+	if resp.err != nil {
+		return resp.err
+	}
+
+	if resp.content != nil {
+		return cborDecMode.Unmarshal(resp.content, v)
+	}
+	defer resp.Body.Close()
+
+	return cborDecMode.NewDecoder(resp.Body).Decode(v)
+}
+
+// Protobuf decodes the HTTP response body and unmarshals its Protocol Buffers wire-format data into msg.
+// It works with both application/x-protobuf and application/protobuf responses.
+func (resp *Response) Protobuf(msg proto.Message) error {
+	b, err := resp.Content()
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(b, msg)
+}
+
+// ProtobufJSON decodes the HTTP response body and unmarshals its canonical JSON encoding of
+// a Protocol Buffers message into msg. Unknown fields are ignored.
+func (resp *Response) ProtobufJSON(msg proto.Message) error {
+	b, err := resp.Content()
+	if err != nil {
+		return err
+	}
+
+	return protojsonUnmarshalOptions.Unmarshal(b, msg)
+}
+
 // Dump returns the HTTP/1.x wire representation of resp.
 func (resp *Response) Dump(withBody bool) ([]byte, error) {
 	if resp.err != nil {
@@ -139,34 +205,78 @@ func (resp *Response) Cookie(name string) (*http.Cookie, error) {
 }
 
 // EnsureStatusOk ensures the HTTP response's status code must be 200.
-func (resp *Response) EnsureStatusOk() *Response {
-	return resp.EnsureStatus(http.StatusOK)
+// See EnsureStatus for the usage of v.
+func (resp *Response) EnsureStatusOk(v ...interface{}) *Response {
+	return resp.EnsureStatus(http.StatusOK, v...)
 }
 
 // EnsureStatus2xx ensures the HTTP response's status code must be 2xx.
-func (resp *Response) EnsureStatus2xx() *Response {
+// See EnsureStatus for the usage of v.
+func (resp *Response) EnsureStatus2xx(v ...interface{}) *Response {
 	if resp.err != nil {
 		return resp
 	}
 
 	if resp.StatusCode/100 != 2 {
-		resp.err = fmt.Errorf("ghttp: bad status (%s)", resp.Status)
+		resp.err = resp.statusError(v...)
 	}
 	return resp
 }
 
 // EnsureStatus ensures the HTTP response's status code must be code.
-func (resp *Response) EnsureStatus(code int) *Response {
+// Otherwise resp's error would be a *StatusError, and the HTTP response body would be decoded into
+// the optional v according to its Content-Type, then v is kept as the Problem field of the *StatusError.
+// v must be a pointer. If v not specified, only application/problem+json body would be decoded into a *Problem.
+func (resp *Response) EnsureStatus(code int, v ...interface{}) *Response {
 	if resp.err != nil {
 		return resp
 	}
 
 	if resp.StatusCode != code {
-		resp.err = fmt.Errorf("ghttp: bad status (%s)", resp.Status)
+		resp.err = resp.statusError(v...)
 	}
 	return resp
 }
 
+func (resp *Response) statusError(v ...interface{}) *StatusError {
+	e := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+
+	resp.Prefetch()
+	if resp.err != nil {
+		resp.err = nil
+		return e
+	}
+
+	e.Body = resp.content
+	if len(e.Body) > maxStatusErrorBodySize {
+		e.Body = e.Body[:maxStatusErrorBodySize]
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case len(v) > 0:
+		var err error
+		if strings.HasSuffix(mediaType, "xml") {
+			err = xml.Unmarshal(resp.content, v[0])
+		} else {
+			err = json.Unmarshal(resp.content, v[0])
+		}
+		if err == nil {
+			e.Problem = v[0]
+		}
+	case mediaType == MIMEProblemJSON:
+		p := new(Problem)
+		if err := json.Unmarshal(resp.content, p); err == nil {
+			e.Problem = p
+		}
+	}
+	return e
+}
+
 // Save saves the HTTP response into a file.
 func (resp *Response) Save(filename string, perm os.FileMode) error {
 	if resp.err != nil {
@@ -2,6 +2,7 @@ package ghttp
 
 import (
 	"encoding/xml"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -194,6 +195,58 @@ func TestResponse_XML(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestResponse_MsgPack(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/msgpack")
+		w.Write([]byte{0x81, 0xa2, 'i', 'd', 0x01})
+	}))
+	defer ts.Close()
+
+	var data struct {
+		ID int `msgpack:"id"`
+	}
+	client := New()
+	err := client.
+		Get(ts.URL).
+		Prefetch().
+		MsgPack(&data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, data.ID)
+	}
+
+	err = client.
+		Get("https://www.google.com/404").
+		EnsureStatusOk().
+		MsgPack(&data)
+	assert.Error(t, err)
+}
+
+func TestResponse_CBOR(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/cbor")
+		w.Write([]byte{0xa1, 0x62, 'i', 'd', 0x01})
+	}))
+	defer ts.Close()
+
+	var data struct {
+		ID int `cbor:"id"`
+	}
+	client := New()
+	err := client.
+		Get(ts.URL).
+		Prefetch().
+		CBOR(&data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, data.ID)
+	}
+
+	err = client.
+		Get("https://www.google.com/404").
+		EnsureStatusOk().
+		CBOR(&data)
+	assert.Error(t, err)
+}
+
 func TestResponse_Dump(t *testing.T) {
 	client := New()
 	_, err := client.
@@ -284,6 +337,73 @@ func TestResponse_EnsureStatus(t *testing.T) {
 	assert.Error(t, err)
 }
 
+type testAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *testAPIError) Error() string {
+	return e.Message
+}
+
+func TestResponse_EnsureStatusWithProblem(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/problem":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"title":"Not Found","status":404}`))
+		case "/api":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":10001,"message":"invalid uid"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(strings.Repeat("x", 2048)))
+		}
+	}))
+	defer ts.Close()
+
+	client := New()
+	err := client.
+		Get(ts.URL + "/problem").
+		EnsureStatus2xx().
+		Err()
+	var se *StatusError
+	if assert.True(t, errors.As(err, &se)) {
+		assert.Equal(t, http.StatusNotFound, se.StatusCode)
+		assert.Equal(t, "application/problem+json", se.Header.Get("Content-Type"))
+		assert.Equal(t, `{"title":"Not Found","status":404}`, string(se.Body))
+		problem, ok := se.Problem.(*Problem)
+		if assert.True(t, ok) {
+			assert.Equal(t, "Not Found", problem.Title)
+		}
+		assert.Equal(t, problem, se.Unwrap())
+	}
+
+	apiErr := new(testAPIError)
+	err = client.
+		Get(ts.URL + "/api").
+		EnsureStatusOk(apiErr).
+		Err()
+	var e *testAPIError
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Equal(t, 10001, e.Code)
+		assert.Equal(t, "invalid uid", e.Message)
+	}
+	assert.EqualError(t, err, "ghttp: bad status (400 Bad Request)")
+
+	err = client.
+		Get(ts.URL).
+		EnsureStatus(http.StatusOK, new(testAPIError)).
+		Err()
+	if assert.True(t, errors.As(err, &se)) {
+		assert.Equal(t, http.StatusInternalServerError, se.StatusCode)
+		assert.Len(t, se.Body, 1024)
+		assert.Nil(t, se.Problem)
+	}
+}
+
 func TestResponse_Save(t *testing.T) {
 	client := New()
 	err := client.
@@ -0,0 +1,182 @@
+package ghttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+)
+
+const (
+	sessionVersion = 1
+)
+
+// Authentication schemes of the session state.
+const (
+	sessionAuthBasic  = "basic"
+	sessionAuthBearer = "bearer"
+	sessionAuthAPIKey = "api_key"
+	sessionAuthDigest = "digest"
+)
+
+type sessionState struct {
+	Version     int          `json:"version"`
+	Cookies     []*jarEntry  `json:"cookies,omitempty"`
+	Auth        *sessionAuth `json:"auth,omitempty"`
+	OAuth2Token *OAuth2Token `json:"oauth2_token,omitempty"`
+	Proxy       string       `json:"proxy,omitempty"`
+	BaseURL     string       `json:"base_url,omitempty"`
+
+	DefaultHeaders http.Header   `json:"default_headers,omitempty"`
+	DefaultQuery   neturl.Values `json:"default_query,omitempty"`
+	DefaultCookies Cookies       `json:"default_cookies,omitempty"`
+}
+
+// sessionAuth records the credentials of the authenticators which can be rebuilt from them.
+type sessionAuth struct {
+	Scheme   string `json:"scheme"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Value    string `json:"value,omitempty"`
+	InQuery  bool   `json:"in_query,omitempty"`
+}
+
+// SaveSession serializes the session state of c, so that it can be restored into a new Client by RestoreSession.
+// The session state includes the cookies, including session cookies, the credentials of the authenticator,
+// the proxy set by SetProxyFromURL, the base URL and the defaults such as headers, query parameters and cookies.
+// The authenticator must be one of the basic, bearer, API key, digest and *OAuth2 authenticators if set,
+// only the token is saved for *OAuth2 since its configuration may contain functions.
+// The cookie jar must be a *PersistentJar if set, e.g. the default one of EnableSession.
+// Note that the session state contains credentials, keep it safe.
+func (c *Client) SaveSession() ([]byte, error) {
+	state := &sessionState{
+		Version: sessionVersion,
+		Proxy:   c.proxyURL,
+
+		DefaultHeaders: c.defaultHeaders,
+		DefaultQuery:   c.defaultQuery,
+		DefaultCookies: c.defaultCookies,
+	}
+
+	if c.Jar != nil {
+		jar, ok := c.Jar.(*PersistentJar)
+		if !ok {
+			return nil, &Error{
+				Op:  "Client.SaveSession",
+				Err: ErrCookieJarNotEnumerable,
+			}
+		}
+		state.Cookies = jar.snapshot()
+	}
+
+	if c.baseURL != nil {
+		state.BaseURL = c.baseURL.String()
+	}
+
+	switch a := c.authenticator.(type) {
+	case nil:
+	case *basicAuthenticator:
+		state.Auth = &sessionAuth{Scheme: sessionAuthBasic, Username: a.username, Password: a.password}
+	case *bearerAuthenticator:
+		state.Auth = &sessionAuth{Scheme: sessionAuthBearer, Token: a.token}
+	case *apiKeyAuthenticator:
+		state.Auth = &sessionAuth{Scheme: sessionAuthAPIKey, Name: a.name, Value: a.value, InQuery: a.inQuery}
+	case *DigestAuthenticator:
+		state.Auth = &sessionAuth{Scheme: sessionAuthDigest, Username: a.username, Password: a.password}
+	case *OAuth2:
+		a.mu.Lock()
+		state.OAuth2Token = a.token
+		a.mu.Unlock()
+	default:
+		return nil, &Error{
+			Op:  "Client.SaveSession",
+			Err: fmt.Errorf("unsupported authenticator %T", a),
+		}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, &Error{
+			Op:  "Client.SaveSession",
+			Err: err,
+		}
+	}
+	return data, nil
+}
+
+// RestoreSession restores the session state saved by SaveSession into c.
+// If there are cookies and the cookie jar is nil, a *PersistentJar would be used.
+// The saved authenticator replaces the one of c, except for *OAuth2, whose token is restored only if
+// c uses an *OAuth2 authenticator, since it can't be rebuilt without the configuration.
+func (c *Client) RestoreSession(data []byte) error {
+	state := new(sessionState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return &Error{
+			Op:  "Client.RestoreSession",
+			Err: err,
+		}
+	}
+	if state.Version != sessionVersion {
+		return &Error{
+			Op:  "Client.RestoreSession",
+			Err: fmt.Errorf("unsupported session version %d", state.Version),
+		}
+	}
+
+	if len(state.Cookies) > 0 {
+		if c.Jar == nil {
+			c.Jar = NewPersistentJar()
+		}
+		if jar, ok := c.Jar.(*PersistentJar); ok {
+			jar.add(state.Cookies)
+		} else {
+			for _, e := range state.Cookies {
+				cookie := e.cookie()
+				if e.HostOnly {
+					cookie.Domain = ""
+				}
+				c.Jar.SetCookies(e.url(), []*http.Cookie{cookie})
+			}
+		}
+	}
+
+	if auth := state.Auth; auth != nil {
+		switch auth.Scheme {
+		case sessionAuthBasic:
+			c.UseAuthenticator(NewBasicAuthenticator(auth.Username, auth.Password))
+		case sessionAuthBearer:
+			c.UseAuthenticator(NewBearerAuthenticator(auth.Token))
+		case sessionAuthAPIKey:
+			c.UseAuthenticator(NewAPIKeyAuthenticator(auth.Name, auth.Value, auth.InQuery))
+		case sessionAuthDigest:
+			c.UseAuthenticator(NewDigestAuthenticator(auth.Username, auth.Password))
+		default:
+			return &Error{
+				Op:  "Client.RestoreSession",
+				Err: fmt.Errorf("unsupported authentication scheme %q", auth.Scheme),
+			}
+		}
+	}
+
+	if o, ok := c.authenticator.(*OAuth2); ok && state.OAuth2Token != nil {
+		o.SetToken(state.OAuth2Token)
+	}
+
+	if state.Proxy != "" {
+		c.SetProxyFromURL(state.Proxy)
+	}
+	if state.BaseURL != "" {
+		c.SetBaseURL(state.BaseURL)
+	}
+
+	for k, vs := range state.DefaultHeaders {
+		c.SetDefaultHeaders(Headers{k: vs})
+	}
+	for k, vs := range state.DefaultQuery {
+		c.SetDefaultQuery(Params{k: vs})
+	}
+	c.SetDefaultCookies(state.DefaultCookies)
+	return nil
+}
@@ -0,0 +1,88 @@
+package ghttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SaveSession(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, _ := r.Cookie("uid")
+		if cookie == nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	token := &OAuth2Token{
+		AccessToken: "hello",
+		Expiry:      time.Now().Add(time.Hour).Truncate(time.Second),
+	}
+	client := New().
+		EnableSession(NewPersistentJar()).
+		UseAuthenticator(NewOAuth2(&OAuth2Config{}).SetToken(token)).
+		SetCookies(ts.URL, &http.Cookie{Name: "uid", Value: "1"}).
+		SetProxyFromURL("http://127.0.0.1:1081").
+		SetDefaultHeaders(Headers{"X-Api-Version": "2"}).
+		SetDefaultQuery(Params{"tenant": "t1"}).
+		SetDefaultCookies(Cookies{"lang": "en"}).
+		SetBaseURL(ts.URL)
+	data, err := client.SaveSession()
+	require.NoError(t, err)
+
+	restored := New().UseAuthenticator(NewOAuth2(&OAuth2Config{}))
+	require.NoError(t, restored.RestoreSession(data))
+	assert.IsType(t, (*PersistentJar)(nil), restored.Jar)
+	assert.Equal(t, "http://127.0.0.1:1081", restored.proxyURL)
+	assert.Equal(t, "2", restored.defaultHeaders.Get("X-Api-Version"))
+	assert.Equal(t, "t1", restored.defaultQuery.Get("tenant"))
+	assert.Equal(t, Cookies{"lang": "en"}, restored.defaultCookies)
+	proxy, err := restored.Transport.(*http.Transport).Proxy(httptest.NewRequest(http.MethodGet, ts.URL, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:1081", proxy.Host)
+
+	restored.DisableProxy()
+	text, err := restored.Get("/").Text()
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer hello", text)
+
+	restored = New().EnableSession(newCookieJar())
+	require.NoError(t, restored.RestoreSession(data))
+	cookie, err := restored.FilterCookie(ts.URL, "uid")
+	require.NoError(t, err)
+	assert.Equal(t, "1", cookie.Value)
+
+	_, err = restored.SaveSession()
+	assert.True(t, errors.Is(err, ErrCookieJarNotEnumerable))
+
+	assert.Error(t, restored.RestoreSession([]byte("{")))
+	assert.Error(t, restored.RestoreSession([]byte(`{"version":0}`)))
+	assert.Error(t, restored.RestoreSession([]byte(`{"version":1,"auth":{"scheme":"foo"}}`)))
+
+	tests := []Authenticator{
+		NewBasicAuthenticator("user", "pass"),
+		NewBearerAuthenticator("hello"),
+		NewAPIKeyAuthenticator("X-Api-Key", "secret", false),
+		NewAPIKeyAuthenticator("api_key", "secret", true),
+		NewDigestAuthenticator("user", "pass"),
+	}
+	for _, authenticator := range tests {
+		data, err = New().EnableSession().UseAuthenticator(authenticator).SaveSession()
+		require.NoError(t, err)
+		restored = New()
+		require.NoError(t, restored.RestoreSession(data))
+		assert.Equal(t, authenticator, restored.authenticator)
+	}
+
+	_, err = New().UseAuthenticator(AuthenticatorFunc(func(*Request) error { return nil })).SaveSession()
+	assert.Error(t, err)
+}
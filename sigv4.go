@@ -0,0 +1,323 @@
+package ghttp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4DateFormat = "20060102"
+
+	// UnsignedPayload is the payload hash value used when the payload is not signed.
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var (
+	// sigV4IgnoredHeaders lists the headers which may be changed by proxies or the HTTP client.
+	sigV4IgnoredHeaders = map[string]bool{
+		"Authorization":   true,
+		"User-Agent":      true,
+		"X-Amzn-Trace-Id": true,
+		"Expect":          true,
+		"Connection":      true,
+	}
+)
+
+type (
+	// SigV4Signer signs requests with AWS Signature Version 4.
+	// It implements Authenticator interface, so it can be used with Client.UseAuthenticator,
+	// or its Authenticate method can be used as a BeforeRequestHook.
+	SigV4Signer struct {
+		accessKeyID     string
+		secretAccessKey string
+		sessionToken    string
+		region          string
+		service         string
+		unsignedPayload bool
+		now             func() time.Time
+	}
+)
+
+// NewSigV4Signer returns a new SigV4Signer given the credentials, region and service name.
+func NewSigV4Signer(accessKeyID string, secretAccessKey string, region string, service string) *SigV4Signer {
+	return &SigV4Signer{
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		region:          region,
+		service:         service,
+		now:             time.Now,
+	}
+}
+
+// SetSessionToken sets the session token of the temporary security credentials.
+func (s *SigV4Signer) SetSessionToken(token string) *SigV4Signer {
+	s.sessionToken = token
+	return s
+}
+
+// SetUnsignedPayload makes s not hash the payload, it's typically used for large S3 uploads.
+func (s *SigV4Signer) SetUnsignedPayload(unsignedPayload bool) *SigV4Signer {
+	s.unsignedPayload = unsignedPayload
+	return s
+}
+
+// Authenticate implements Authenticator interface.
+func (s *SigV4Signer) Authenticate(req *Request) error {
+	return s.Sign(req)
+}
+
+// Challenge implements Authenticator interface.
+func (s *SigV4Signer) Challenge(*Request, *Response) (bool, error) {
+	return false, nil
+}
+
+// Sign signs req by setting its Authorization header, along with X-Amz-Date and other headers.
+// If the request body cannot be replayed, it will be read into memory to compute the payload hash.
+func (s *SigV4Signer) Sign(req *Request) error {
+	now := s.now().UTC()
+	payloadHash, err := s.payloadHash(req)
+	if err != nil {
+		return &Error{
+			Op:  "SigV4Signer.Sign",
+			Err: err,
+		}
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	if s.service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(now)
+	req.Header.Set("Authorization", sigV4Algorithm+
+		" Credential="+s.accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+s.signature(now, scope, canonicalRequest))
+	return nil
+}
+
+// Presign returns the presigned URL of req which is valid for the given duration.
+// The payload of req is not signed.
+func (s *SigV4Signer) Presign(req *Request, expires time.Duration) (string, error) {
+	now := s.now().UTC()
+	scope := s.scope(now)
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req)
+
+	u := *req.URL
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.accessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+	if s.sessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(&u),
+		canonicalQuery(query),
+		canonicalHeaders,
+		signedHeaders,
+		UnsignedPayload,
+	}, "\n")
+
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + s.signature(now, scope, canonicalRequest)
+	return u.String(), nil
+}
+
+func (s *SigV4Signer) scope(t time.Time) string {
+	return t.Format(sigV4DateFormat) + "/" + s.region + "/" + s.service + "/aws4_request"
+}
+
+func (s *SigV4Signer) signature(t time.Time, scope string, canonicalRequest string) string {
+	stringToSign := sigV4Algorithm + "\n" +
+		t.Format(sigV4TimeFormat) + "\n" +
+		scope + "\n" +
+		hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), t.Format(sigV4DateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func (s *SigV4Signer) payloadHash(req *Request) (string, error) {
+	if s.unsignedPayload {
+		return UnsignedPayload, nil
+	}
+
+	h := sha256.New()
+	if req.Body == nil || req.Body == http.NoBody {
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	if req.GetBody == nil {
+		body, err := drainBody(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.SetBody(body)
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	if _, err = io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalURI returns the URI-encoded path of u.
+// Each path segment is URI-encoded twice and the path is normalized, except for S3.
+func (s *SigV4Signer) canonicalURI(u *neturl.URL) string {
+	p := u.Path
+	if p == "" {
+		return "/"
+	}
+
+	if s.service != "s3" {
+		cleaned := path.Clean(p)
+		if strings.HasSuffix(p, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		p = cleaned
+	}
+
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		seg = uriEncode(seg)
+		if s.service != "s3" {
+			seg = uriEncode(seg)
+		}
+		segments[i] = seg
+	}
+	return strings.Join(segments, "/")
+}
+
+func (s *SigV4Signer) canonicalHeaders(req *Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values := map[string]string{
+		"host": host,
+	}
+	for k, vs := range req.Header {
+		if sigV4IgnoredHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[strings.ToLower(k)] = strings.Join(trimmed, ",")
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte(':')
+		sb.WriteString(values[k])
+		sb.WriteByte('\n')
+	}
+	return strings.Join(keys, ";"), sb.String()
+}
+
+func canonicalQuery(query neturl.Values) string {
+	keys := make([]string, 0, len(query))
+	encoded := make(map[string][]string, len(query))
+	for k, vs := range query {
+		ek := uriEncode(k)
+		keys = append(keys, ek)
+		for _, v := range vs {
+			encoded[ek] = append(encoded[ek], uriEncode(v))
+		}
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		vs := encoded[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(k)
+			sb.WriteByte('=')
+			sb.WriteString(v)
+		}
+	}
+	return sb.String()
+}
+
+// uriEncode encodes s as AWS requires, every byte except the unreserved characters is percent-encoded.
+func uriEncode(s string) string {
+	const hexUpper = "0123456789ABCDEF"
+
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			buf.WriteByte(c)
+		} else {
+			buf.WriteByte('%')
+			buf.WriteByte(hexUpper[c>>4])
+			buf.WriteByte(hexUpper[c&15])
+		}
+	}
+	return buf.String()
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, data)
+	return mac.Sum(nil)
+}
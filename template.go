@@ -0,0 +1,418 @@
+package ghttp
+
+import (
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// pathParamRegexp matches {name} in a path, the braces may be escaped.
+	pathParamRegexp = regexp.MustCompile(`(?i)(?:\{|%7B)([^/{}%]+)(?:\}|%7D)`)
+
+	errMalformedURITemplate = errors.New("malformed URI template")
+)
+
+// uriTemplateOperator specifies how to expand an RFC 6570 expression.
+type uriTemplateOperator struct {
+	first         string
+	sep           string
+	named         bool
+	ifEmpty       string
+	allowReserved bool
+}
+
+var uriTemplateOperators = map[byte]*uriTemplateOperator{
+	'+': {sep: ",", allowReserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "="},
+	'#': {first: "#", sep: ",", allowReserved: true},
+}
+
+// SetPathParams replaces the {name} placeholders in the URL path of the HTTP request.
+// params can be a map with string keys or a struct, the struct fields can be renamed by the "path" tag,
+// e.g. `path:"id"`, or ignored by `path:"-"`. Each value is escaped as a single path segment,
+// so "a/b" is sent as "a%2Fb".
+func (req *Request) SetPathParams(params interface{}) error {
+	values, err := pathParamValues(params)
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetPathParams",
+			Err: err,
+		}
+	}
+
+	escaped := req.URL.EscapedPath()
+	escaped = pathParamRegexp.ReplaceAllStringFunc(escaped, func(s string) string {
+		name := pathParamRegexp.FindStringSubmatch(s)[1]
+		value, ok := values[name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("missing path parameter %q", name)
+			}
+			return s
+		}
+		return neturl.PathEscape(value)
+	})
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetPathParams",
+			Err: err,
+		}
+	}
+
+	path, err := neturl.PathUnescape(escaped)
+	if err != nil {
+		return &Error{
+			Op:  "Request.SetPathParams",
+			Err: err,
+		}
+	}
+
+	req.URL.Path = path
+	req.URL.RawPath = escaped
+	return nil
+}
+
+// WithPathParams is a request option to replace the {name} placeholders in the URL path of the HTTP request.
+func WithPathParams(params interface{}) RequestOption {
+	return func(req *Request) error {
+		return req.SetPathParams(params)
+	}
+}
+
+func pathParamValues(params interface{}) (map[string]string, error) {
+	rv := reflect.ValueOf(params)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+
+	values := make(map[string]string)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported path parameters type %T", params)
+		}
+
+		iter := rv.MapRange()
+		for iter.Next() {
+			s, err := toString(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			values[iter.Key().String()] = s
+		}
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			name := field.Name
+			if tag := field.Tag.Get("path"); tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+
+			s, err := toString(rv.Field(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			values[name] = s
+		}
+	default:
+		return nil, fmt.Errorf("unsupported path parameters type %T", params)
+	}
+	return values, nil
+}
+
+// ExpandURITemplate expands an RFC 6570 URI Template given the variables, all the levels 1-4 are supported.
+// A variable can be a string, number, bool, a list ([]string or []interface{}) or an associative array
+// (map[string]string or map[string]interface{}, expanded in key order). A nil value, an empty list and an empty
+// associative array are considered as undefined.
+//
+// For example:
+//
+//	ExpandURITemplate("/repos/{owner}/{repo}/issues{?state,labels*}", H{
+//		"owner":  "winterssy",
+//		"repo":   "ghttp",
+//		"state":  "open",
+//		"labels": []string{"bug", "help wanted"},
+//	})
+//
+// returns "/repos/winterssy/ghttp/issues?state=open&labels=bug&labels=help%20wanted".
+func ExpandURITemplate(template string, vars map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	for len(template) > 0 {
+		i := strings.IndexAny(template, "{}")
+		if i < 0 {
+			sb.WriteString(encodeURITemplateValue(template, true))
+			break
+		}
+		if template[i] == '}' {
+			return "", &Error{
+				Op:  "ExpandURITemplate",
+				Err: errMalformedURITemplate,
+			}
+		}
+
+		sb.WriteString(encodeURITemplateValue(template[:i], true))
+		template = template[i+1:]
+		j := strings.IndexByte(template, '}')
+		if j < 0 {
+			return "", &Error{
+				Op:  "ExpandURITemplate",
+				Err: errMalformedURITemplate,
+			}
+		}
+
+		if err := expandURITemplateExpression(&sb, template[:j], vars); err != nil {
+			return "", &Error{
+				Op:  "ExpandURITemplate",
+				Err: err,
+			}
+		}
+		template = template[j+1:]
+	}
+	return sb.String(), nil
+}
+
+func expandURITemplateExpression(sb *strings.Builder, expr string, vars map[string]interface{}) error {
+	op := &uriTemplateOperator{sep: ","}
+	if expr != "" {
+		if o, ok := uriTemplateOperators[expr[0]]; ok {
+			op = o
+			expr = expr[1:]
+		}
+	}
+	if expr == "" {
+		return errMalformedURITemplate
+	}
+
+	first := true
+	for _, spec := range strings.Split(expr, ",") {
+		name, explode, prefix, err := parseVarSpec(spec)
+		if err != nil {
+			return err
+		}
+
+		s, ok, err := expandURITemplateVar(op, name, explode, prefix, vars[name])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		if first {
+			sb.WriteString(op.first)
+			first = false
+		} else {
+			sb.WriteString(op.sep)
+		}
+		sb.WriteString(s)
+	}
+	return nil
+}
+
+func parseVarSpec(spec string) (name string, explode bool, prefix int, err error) {
+	name = spec
+	if strings.HasSuffix(name, "*") {
+		name = name[:len(name)-1]
+		explode = true
+	} else if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = spec[:i]
+		n := spec[i+1:]
+		if n == "" || len(n) > 4 {
+			return "", false, 0, fmt.Errorf("invalid prefix modifier in %q", spec)
+		}
+		for _, c := range n {
+			if c < '0' || c > '9' {
+				return "", false, 0, fmt.Errorf("invalid prefix modifier in %q", spec)
+			}
+			prefix = prefix*10 + int(c-'0')
+		}
+		if prefix == 0 {
+			return "", false, 0, fmt.Errorf("invalid prefix modifier in %q", spec)
+		}
+	}
+
+	if name == "" {
+		return "", false, 0, fmt.Errorf("invalid variable name in %q", spec)
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(isAlphaNum(c) || c == '_' || c == '.' || c == '%') {
+			return "", false, 0, fmt.Errorf("invalid variable name in %q", spec)
+		}
+	}
+	return
+}
+
+func expandURITemplateVar(op *uriTemplateOperator, name string, explode bool, prefix int,
+	value interface{}) (string, bool, error) {
+	if value == nil {
+		return "", false, nil
+	}
+
+	var sb strings.Builder
+	writeNamed := func(key string, value string) {
+		sb.WriteString(key)
+		if value == "" {
+			sb.WriteString(op.ifEmpty)
+		} else {
+			sb.WriteByte('=')
+			sb.WriteString(value)
+		}
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if _, ok := value.([]byte); ok {
+			break
+		}
+		if rv.Len() == 0 {
+			return "", false, nil
+		}
+		if prefix > 0 {
+			return "", false, fmt.Errorf("prefix modifier not applicable to list %q", name)
+		}
+
+		items := make([]string, rv.Len())
+		for i := range items {
+			s, err := toString(rv.Index(i).Interface())
+			if err != nil {
+				return "", false, err
+			}
+			items[i] = encodeURITemplateValue(s, op.allowReserved)
+		}
+
+		switch {
+		case explode && op.named:
+			for i, item := range items {
+				if i > 0 {
+					sb.WriteString(op.sep)
+				}
+				writeNamed(name, item)
+			}
+		case explode:
+			sb.WriteString(strings.Join(items, op.sep))
+		case op.named:
+			writeNamed(name, strings.Join(items, ","))
+		default:
+			sb.WriteString(strings.Join(items, ","))
+		}
+		return sb.String(), true, nil
+	case reflect.Map:
+		if rv.Len() == 0 {
+			return "", false, nil
+		}
+		if prefix > 0 {
+			return "", false, fmt.Errorf("prefix modifier not applicable to associative array %q", name)
+		}
+		if rv.Type().Key().Kind() != reflect.String {
+			return "", false, fmt.Errorf("unsupported associative array type %T", value)
+		}
+
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, 2*len(keys))
+		for _, k := range keys {
+			s, err := toString(rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())).Interface())
+			if err != nil {
+				return "", false, err
+			}
+			pairs = append(pairs, encodeURITemplateValue(k, op.allowReserved), encodeURITemplateValue(s, op.allowReserved))
+		}
+
+		switch {
+		case explode:
+			for i := 0; i < len(pairs); i += 2 {
+				if i > 0 {
+					sb.WriteString(op.sep)
+				}
+				if op.named {
+					writeNamed(pairs[i], pairs[i+1])
+				} else {
+					sb.WriteString(pairs[i])
+					sb.WriteByte('=')
+					sb.WriteString(pairs[i+1])
+				}
+			}
+		case op.named:
+			writeNamed(name, strings.Join(pairs, ","))
+		default:
+			sb.WriteString(strings.Join(pairs, ","))
+		}
+		return sb.String(), true, nil
+	}
+
+	s, err := toString(value)
+	if err != nil {
+		return "", false, err
+	}
+	if prefix > 0 && utf8.RuneCountInString(s) > prefix {
+		runes := []rune(s)
+		s = string(runes[:prefix])
+	}
+
+	s = encodeURITemplateValue(s, op.allowReserved)
+	if op.named {
+		writeNamed(name, s)
+	} else {
+		sb.WriteString(s)
+	}
+	return sb.String(), true, nil
+}
+
+// encodeURITemplateValue percent-encodes s, only the unreserved characters are kept as is
+// unless allowReserved is true, which keeps the reserved characters and percent-encoded triplets as well.
+func encodeURITemplateValue(s string, allowReserved bool) string {
+	const hex = "0123456789ABCDEF"
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isAlphaNum(c) || strings.IndexByte("-._~", c) >= 0:
+			sb.WriteByte(c)
+		case allowReserved && strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0:
+			sb.WriteByte(c)
+		case allowReserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			sb.WriteString(s[i : i+3])
+			i += 2
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(hex[c>>4])
+			sb.WriteByte(hex[c&0x0F])
+		}
+	}
+	return sb.String()
+}
+
+func isAlphaNum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
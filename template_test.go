@@ -0,0 +1,180 @@
+package ghttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_SetPathParams(t *testing.T) {
+	req, err := NewRequest(MethodGet, "https://api.example.com/users/{id}/repos/{repo}?q={x}",
+		WithPathParams(map[string]interface{}{
+			"id":   1,
+			"repo": "a/b c",
+		}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/users/1/repos/a%2Fb%20c?q={x}", req.URL.String())
+	assert.Equal(t, "/users/1/repos/a/b c", req.URL.Path)
+
+	type params struct {
+		ID      string `path:"id"`
+		Repo    string
+		Ignored string `path:"-"`
+		private string
+	}
+	req, err = NewRequest(MethodGet, "/users/{id}/repos/{Repo}",
+		WithPathParams(&params{ID: "~me", Repo: "ghttp"}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "/users/~me/repos/ghttp", req.URL.String())
+
+	_, err = NewRequest(MethodGet, "/users/{id}", WithPathParams(map[string]string{}))
+	assert.Error(t, err)
+	_, err = NewRequest(MethodGet, "/users/{id}", WithPathParams(1))
+	assert.Error(t, err)
+	_, err = NewRequest(MethodGet, "/users/{id}", WithPathParams(map[string]interface{}{"id": struct{}{}}))
+	assert.Error(t, err)
+}
+
+func TestClient_SetBaseURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	defer ts.Close()
+
+	client := New().SetBaseURL(ts.URL + "/v1/")
+	data, err := client.Get("/users/{id}",
+		WithPathParams(map[string]string{"id": "a/b"}),
+		WithQuery(Params{"k": "v"}),
+	).Text()
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/users/a%2Fb?k=v", data)
+
+	data, err = client.Get("users").Text()
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/users", data)
+
+	data, err = client.Get(ts.URL + "/absolute").Text()
+	require.NoError(t, err)
+	assert.Equal(t, "/absolute", data)
+
+	client.SetBaseURL("not/absolute")
+	assert.Equal(t, ts.URL+"/v1/", client.baseURL.String())
+}
+
+func TestExpandURITemplate(t *testing.T) {
+	vars := H{
+		"count":      []string{"one", "two", "three"},
+		"dom":        []interface{}{"example", "com"},
+		"dub":        "me/too",
+		"hello":      "Hello World!",
+		"half":       "50%",
+		"var":        "value",
+		"who":        "fred",
+		"base":       "http://example.com/home/",
+		"path":       "/foo/bar",
+		"list":       []string{"red", "green", "blue"},
+		"keys":       map[string]string{"semi": ";", "dot": ".", "comma": ","},
+		"v":          6,
+		"x":          1024,
+		"y":          768,
+		"empty":      "",
+		"empty_keys": map[string]string{},
+		"undef":      nil,
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		// Level 1
+		{"{var}", "value"},
+		{"{hello}", "Hello%20World%21"},
+		{"{half}", "50%25"},
+		{"O{empty}X", "OX"},
+		{"O{undef}X", "OX"},
+		{"{x,y}", "1024,768"},
+		{"{x,hello,y}", "1024,Hello%20World%21,768"},
+		{"?{x,empty}", "?1024,"},
+		{"?{x,undef}", "?1024"},
+		{"?{undef,y}", "?768"},
+		{"{var:3}", "val"},
+		{"{var:30}", "value"},
+		{"{list}", "red,green,blue"},
+		{"{list*}", "red,green,blue"},
+		{"{keys}", "comma,%2C,dot,.,semi,%3B"},
+		{"{keys*}", "comma=%2C,dot=.,semi=%3B"},
+
+		// Level 2
+		{"{+var}", "value"},
+		{"{+hello}", "Hello%20World!"},
+		{"{+half}", "50%25"},
+		{"{base}index", "http%3A%2F%2Fexample.com%2Fhome%2Findex"},
+		{"{+base}index", "http://example.com/home/index"},
+		{"{+path}/here", "/foo/bar/here"},
+		{"here?ref={+path}", "here?ref=/foo/bar"},
+		{"{+path:6}/here", "/foo/b/here"},
+		{"{+list}", "red,green,blue"},
+		{"{+keys*}", "comma=,,dot=.,semi=;"},
+		{"X{#var}", "X#value"},
+		{"X{#hello}", "X#Hello%20World!"},
+		{"{#path:6}/here", "#/foo/b/here"},
+		{"{#keys}", "#comma,,,dot,.,semi,;"},
+
+		// Level 3
+		{"X{.var}", "X.value"},
+		{"X{.x,y}", "X.1024.768"},
+		{"X{.empty_keys}", "X"},
+		{"www{.dom*}", "www.example.com"},
+		{"X{.var:3}", "X.val"},
+		{"X{.list*}", "X.red.green.blue"},
+		{"X{.keys*}", "X.comma=%2C.dot=..semi=%3B"},
+		{"{/var}", "/value"},
+		{"{/var,x}/here", "/value/1024/here"},
+		{"{/who,who}", "/fred/fred"},
+		{"{/half,who}", "/50%25/fred"},
+		{"{/who,dub}", "/fred/me%2Ftoo"},
+		{"{/var:1,var}", "/v/value"},
+		{"{/list*}", "/red/green/blue"},
+		{"{/list*,path:4}", "/red/green/blue/%2Ffoo"},
+		{"{/keys*}", "/comma=%2C/dot=./semi=%3B"},
+		{"{;who}", ";who=fred"},
+		{"{;half}", ";half=50%25"},
+		{"{;empty}", ";empty"},
+		{"{;v,empty,who}", ";v=6;empty;who=fred"},
+		{"{;x,y,undef}", ";x=1024;y=768"},
+		{"{;hello:5}", ";hello=Hello"},
+		{"{;list}", ";list=red,green,blue"},
+		{"{;list*}", ";list=red;list=green;list=blue"},
+		{"{;keys*}", ";comma=%2C;dot=.;semi=%3B"},
+		{"{?who}", "?who=fred"},
+		{"{?half}", "?half=50%25"},
+		{"{?x,y,empty}", "?x=1024&y=768&empty="},
+		{"{?x,y,undef}", "?x=1024&y=768"},
+		{"{?var:3}", "?var=val"},
+		{"{?list}", "?list=red,green,blue"},
+		{"{?list*}", "?list=red&list=green&list=blue"},
+		{"{?keys}", "?keys=comma,%2C,dot,.,semi,%3B"},
+		{"{?keys*}", "?comma=%2C&dot=.&semi=%3B"},
+		{"?fixed=yes{&x}", "?fixed=yes&x=1024"},
+		{"{&var:3}", "&var=val"},
+		{"{&list*}", "&list=red&list=green&list=blue"},
+		{"{&keys*}", "&comma=%2C&dot=.&semi=%3B"},
+		{"{?count*}", "?count=one&count=two&count=three"},
+	}
+	for _, test := range tests {
+		got, err := ExpandURITemplate(test.template, vars)
+		if assert.NoError(t, err, test.template) {
+			assert.Equal(t, test.want, got, test.template)
+		}
+	}
+
+	for _, template := range []string{"{var", "var}", "{}", "{+}", "{var:0}", "{var:abc}", "{list:1}", "{va r}"} {
+		_, err := ExpandURITemplate(template, vars)
+		assert.Error(t, err, template)
+	}
+}
@@ -2,17 +2,27 @@ package ghttp
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"reflect"
 	"strconv"
 	"sync"
+	"time"
 	"unsafe"
+
+	"github.com/fxamacker/cbor/v2"
 )
 
 var (
 	bufPool = &sync.Pool{New: func() interface{} { return &bytes.Buffer{} }}
+
+	// cborDecMode decodes CBOR maps into map[string]interface{} so that the result can be used as H.
+	cborDecMode, _ = cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
 )
 
 type (
@@ -155,12 +165,12 @@ func (h H) GetBool(key string) bool {
 // GetNumberDefault gets the Number value associated with key.
 // The defaultValue is returned if the key not exists.
 func (h H) GetNumberDefault(key string, defaultValue Number) Number {
-	v, ok := h[key].(float64)
+	v, ok := toNumber(h[key])
 	if !ok {
 		return defaultValue
 	}
 
-	return Number(v)
+	return v
 }
 
 // GetNumber gets the Number value associated with key.
@@ -216,8 +226,8 @@ func (h H) GetNumberSlice(key string) []Number {
 	v := h.GetSlice(key)
 	vs := make([]Number, 0, len(v))
 	for _, vv := range v {
-		if vv, ok := vv.(float64); ok {
-			vs = append(vs, Number(vv))
+		if vv, ok := toNumber(vv); ok {
+			vs = append(vs, vv)
 		}
 	}
 	return vs
@@ -243,41 +253,112 @@ func b2s(b []byte) string {
 	return *(*string)(unsafe.Pointer(&b))
 }
 
-func toString(v interface{}) string {
+func toString(v interface{}) (string, error) {
 	switch v := v.(type) {
 	case string:
-		return v
+		return v, nil
 	case []byte:
-		return b2s(v)
+		return b2s(v), nil
 	case bool:
-		return strconv.FormatBool(v)
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	case time.Duration:
+		return v.String(), nil
+	case encoding.TextMarshaler:
+		if isNilPointer(v) {
+			return "", fmt.Errorf("unexpected nil pointer of type %T", v)
+		}
+		b, err := v.MarshalText()
+		return string(b), err
+	case fmt.Stringer:
+		if isNilPointer(v) {
+			return "", fmt.Errorf("unexpected nil pointer of type %T", v)
+		}
+		return v.String(), nil
+	}
+
+	// Named types of the primitives, e.g. type Status int.
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	}
+
+	return "", fmt.Errorf("unexpected value %#v of type %T", v, v)
+}
+
+// isNilPointer reports whether v is a nil pointer, whose methods may panic.
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// toNumber converts v to a Number if it's numeric.
+// JSON decodes numbers to float64 while MessagePack and CBOR keep their integer types.
+func toNumber(v interface{}) (Number, bool) {
+	switch v := v.(type) {
 	case float64:
-		return strconv.FormatFloat(v, 'f', -1, 64)
+		return Number(v), true
 	case float32:
-		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+		return Number(v), true
 	case int:
-		return strconv.FormatInt(int64(v), 10)
+		return Number(v), true
 	case int64:
-		return strconv.FormatInt(v, 10)
+		return Number(v), true
 	case int32:
-		return strconv.FormatInt(int64(v), 10)
+		return Number(v), true
 	case int16:
-		return strconv.FormatInt(int64(v), 10)
+		return Number(v), true
 	case int8:
-		return strconv.FormatInt(int64(v), 10)
+		return Number(v), true
 	case uint:
-		return strconv.FormatUint(uint64(v), 10)
+		return Number(v), true
 	case uint64:
-		return strconv.FormatUint(v, 10)
+		return Number(v), true
 	case uint32:
-		return strconv.FormatUint(uint64(v), 10)
+		return Number(v), true
 	case uint16:
-		return strconv.FormatUint(uint64(v), 10)
+		return Number(v), true
 	case uint8:
-		return strconv.FormatUint(uint64(v), 10)
+		return Number(v), true
 	}
 
-	panic(fmt.Errorf("ghttp: unexpected value %#v of type %T", v, v))
+	return 0, false
 }
 
 var jsonSuffix = []byte{'\n'}
@@ -169,12 +169,25 @@ func TestToString(t *testing.T) {
 		{testFloat64Val, testFloat64ValStr},
 	}
 	for _, test := range tests {
-		assert.Equal(t, test.want, toString(test.input))
+		s, err := toString(test.input)
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.want, s)
+		}
 	}
 
-	assert.Panics(t, func() {
-		toString(testInvalidVal)
-	})
+	type status int
+	s, err := toString(status(1))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1", s)
+	}
+
+	_, err = toString(testInvalidVal)
+	assert.Error(t, err)
+
+	_, err = toString((*time.Time)(nil))
+	assert.Error(t, err)
+	_, err = toString((*Number)(nil))
+	assert.Error(t, err)
 }
 
 func TestToJSON(t *testing.T) {